@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"crypto/md5"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 	"sync"
@@ -30,25 +31,78 @@ func checkCacheControl(req *http.Request) bool {
 	}
 	return false
 }
+
+// ETagBufferSize ETagMiddleware 缓存响应体的上限，超出或中途刷新时直接写出，不带 ETag
+var ETagBufferSize = 1 << 20
+
 func ETagMiddleware(etag *sync.Map) func(*HTTPContext) {
 	return func(c *HTTPContext) {
-		f := func(b *bytes.Buffer) *bytes.Buffer {
-			if c.status == http.StatusOK {
-				ETag := fmt.Sprintf("%x", (md5.Sum(b.Bytes())))
-				etag.Store(c.Request.URL.RequestURI(), ETag)
-				c.Writer.Header().Set("ETag", ETag)
+		key := c.Request.URL.RequestURI()
+		pool := c.route.pool
+		c.Transform(func(h http.Header, status int, w io.Writer) io.WriteCloser {
+			if status != http.StatusOK {
+				return nil
 			}
-			return b
-		}
-		c.HookBeforWriteHeader = append(c.HookBeforWriteHeader, f)
+			return &etagWriter{h: h, w: w, buf: pool.AllocBuffer(), free: pool.FreeBuffer,
+				store: func(tag string) { etag.Store(key, tag) }, drop: func() { etag.Delete(key) }}
+		})
 		if !checkCacheControl(c.Request) {
-			ETag, ok := etag.Load(c.Request.URL.RequestURI())
+			ETag, ok := etag.Load(key)
 			if ok && strings.Contains(c.Request.Header.Get("If-None-Match"), ETag.(string)) {
-				c.status = http.StatusNotModified
 				c.Writer.WriteHeader(http.StatusNotModified)
+				c.Abort()
 				return
 			}
 		}
 		c.Next()
 	}
 }
+
+// etagWriter 缓存响应体，结束时计算 ETag 并在写出前设置响应头
+type etagWriter struct {
+	h     http.Header
+	w     io.Writer
+	buf   *bytes.Buffer
+	free  func(*bytes.Buffer)
+	store func(string)
+	drop  func()
+	// 超出上限或已刷新，此后直接写出
+	passthrough bool
+}
+
+func (e *etagWriter) Write(p []byte) (int, error) {
+	if !e.passthrough && e.buf.Len()+len(p) > ETagBufferSize {
+		if err := e.Flush(); err != nil {
+			return 0, err
+		}
+	}
+	if e.passthrough {
+		return e.w.Write(p)
+	}
+	return e.buf.Write(p)
+}
+
+// Flush 放弃计算 ETag，写出已缓存的数据
+func (e *etagWriter) Flush() error {
+	if e.passthrough {
+		return nil
+	}
+	e.passthrough = true
+	e.drop()
+	_, err := e.w.Write(e.buf.Bytes())
+	e.free(e.buf)
+	e.buf = nil
+	return err
+}
+
+func (e *etagWriter) Close() error {
+	if e.passthrough {
+		return nil
+	}
+	defer e.free(e.buf)
+	tag := fmt.Sprintf("%x", md5.Sum(e.buf.Bytes()))
+	e.store(tag)
+	e.h.Set("ETag", tag)
+	_, err := e.w.Write(e.buf.Bytes())
+	return err
+}
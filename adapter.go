@@ -0,0 +1,58 @@
+package whttp
+
+import (
+	"net/http"
+	"slices"
+)
+
+// WrapHandler 将标准 http.Handler 作为处理链的最后一个函数，其输出同样经过 Transformer
+func WrapHandler(h http.Handler) func(*HTTPContext) {
+	if h == nil {
+		panic("handler cannot be nil")
+	}
+	return func(c *HTTPContext) {
+		h.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+// WrapMiddleware 将 func(http.Handler) http.Handler 形式的中间件转为 whttp 中间件，
+// 标准中间件调用 next 时执行 c.Next()，其替换的 ResponseWriter 与 *http.Request 对后续处理可见，
+// 返回后恢复；未调用 next 时其自身的输出同样经过 Transformer
+func WrapMiddleware(mw func(http.Handler) http.Handler) func(*HTTPContext) {
+	if mw == nil {
+		panic("middleware cannot be nil")
+	}
+	return func(c *HTTPContext) {
+		writer, req := c.Writer, c.Request
+		next := http.HandlerFunc(func(nw http.ResponseWriter, nr *http.Request) {
+			c.Writer, c.Request = nw, nr
+			c.Next()
+		})
+		mw(next).ServeHTTP(writer, req)
+		c.Writer, c.Request = writer, req
+	}
+}
+
+// ToHandler 将处理链转为标准 http.Handler，使用本路由的上下文池、模板与日志，不经过全局中间件
+func (r *WRoute) ToHandler(fn ...func(*HTTPContext)) http.Handler {
+	if len(fn) == 0 {
+		panic("handler cannot be empty")
+	}
+	for _, v := range fn {
+		if v == nil {
+			panic("middleware cannot be nil")
+		}
+	}
+	chain := slices.Clone(fn)
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		r.serve(rw, req, chain)
+	})
+}
+
+// ToMiddleware 将 whttp 中间件转为 func(http.Handler) http.Handler，
+// 中间件调用 c.Next() 时执行 next，next 的输出经过 Transformer
+func (r *WRoute) ToMiddleware(mw ...func(*HTTPContext)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return r.ToHandler(append(slices.Clip(mw), WrapHandler(next))...)
+	}
+}
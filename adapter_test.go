@@ -0,0 +1,107 @@
+package whttp
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type adapterKey struct{}
+
+func gunzip(t *testing.T, rec *httptest.ResponseRecorder) string {
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("got Content-Encoding %q | expected gzip", rec.Header().Get("Content-Encoding"))
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestWrapMiddleware(t *testing.T) {
+	std := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("deny") == "1" {
+				http.Error(w, "denied", http.StatusForbidden)
+				return
+			}
+			w.Header().Set("X-Std", "1")
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), adapterKey{}, "value")))
+		})
+	}
+	r := NewRoute(nil)
+	r.GET("/", GZIPMiddleware(gzip.DefaultCompression), WrapMiddleware(std), func(c *HTTPContext) {
+		v, _ := c.Request.Context().Value(adapterKey{}).(string)
+		c.String(http.StatusOK, v)
+	})
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if body := gunzip(t, rec); body != "value" {
+		t.Errorf("got %s | expected value", body)
+	}
+	if rec.Header().Get("X-Std") != "1" {
+		t.Error("header set by the standard middleware is missing")
+	}
+	req = httptest.NewRequest("GET", "/?deny=1", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("got %d | expected 403", rec.Code)
+	}
+	if body := gunzip(t, rec); body != "denied\n" {
+		t.Errorf("got %s | expected denied", body)
+	}
+}
+
+func TestWrapHandler(t *testing.T) {
+	r := NewRoute(nil)
+	r.GET("/", GZIPMiddleware(gzip.DefaultCompression), WrapHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		io.WriteString(w, "std")
+	})))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("got %d | expected 202", rec.Code)
+	}
+	if body := gunzip(t, rec); body != "std" {
+		t.Errorf("got %s | expected std", body)
+	}
+}
+
+func TestToHandler(t *testing.T) {
+	r := NewRoute(nil)
+	mux := http.NewServeMux()
+	mux.Handle("/w", r.ToHandler(func(c *HTTPContext) { c.String(http.StatusOK, "whttp") }))
+	mw := r.ToMiddleware(func(c *HTTPContext) {
+		c.Writer.Header().Set("X-Whttp", "1")
+		c.Next()
+	})
+	mux.Handle("/s", mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "std")
+	})))
+	tests := [][3]string{
+		{"/w", "whttp", ""},
+		{"/s", "std", "1"},
+	}
+	for i := range tests {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", tests[i][0], nil))
+		if rec.Body.String() != tests[i][1] || rec.Header().Get("X-Whttp") != tests[i][2] {
+			t.Errorf("%s expected %s %s got %s %s", tests[i][0], tests[i][1], tests[i][2], rec.Body.String(), rec.Header().Get("X-Whttp"))
+		}
+	}
+}
@@ -2,10 +2,14 @@ package whttp
 
 import (
 	"encoding/base64"
+	"errors"
 	"net/http"
 	"strings"
 )
 
+// ErrUnauthorized 缺少或错误的 Basic 认证信息
+var ErrUnauthorized = errors.New("unauthorized")
+
 func BasicAuthMiddleware(valid func(c *HTTPContext, username, password string) bool) func(*HTTPContext) {
 	if valid == nil {
 		panic("basicAuthMiddleware: verification function cannot be nil")
@@ -33,7 +37,7 @@ func BasicAuthMiddleware(valid func(c *HTTPContext, username, password string) b
 			}
 		}
 		c.Writer.Header().Set("WWW-Authenticate", "Base realm=\"Restricted\"")
-		c.String(http.StatusUnauthorized, "Unauthorized")
+		c.AbortWithError(http.StatusUnauthorized, ErrUnauthorized)
 	}
 }
 
@@ -0,0 +1,365 @@
+package whttp
+
+import (
+	"encoding"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMultipartMemory BindMultipart 解析时保存在内存中的最大字节数，超出部分写入临时文件
+var DefaultMultipartMemory int64 = 32 << 20
+
+// ErrUnsupportedMediaType Bind 无法识别 Content-Type
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+// BindError 字段绑定失败
+type BindError struct {
+	// 结构体字段名
+	Field string
+	// 来源 query、form、path、header
+	Source string
+	// 来源中的键
+	Key   string
+	Value string
+	Err   error
+}
+
+func (e *BindError) Error() string {
+	return fmt.Sprintf("bind %s %q to %s: %v", e.Source, e.Key, e.Field, e.Err)
+}
+
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+// validate 绑定成功后执行 DefaultValidate
+func validate(v any, err error) error {
+	if err != nil || DefaultValidate == nil {
+		return err
+	}
+	return DefaultValidate(v)
+}
+
+// Bind 绑定路径参数，再按 Content-Type 选择解码器绑定请求体，无请求体时绑定查询字符串，最后校验
+func (c *HTTPContext) Bind(v any) error {
+	c.alive()
+	if err := c.bindPath(v); err != nil {
+		return err
+	}
+	return validate(v, c.bindBody(v))
+}
+
+func (c *HTTPContext) bindBody(v any) error {
+	ct := c.Request.Header.Get(HeaderContentType)
+	if len(ct) == 0 && (c.Request.Body == nil || c.Request.Body == http.NoBody || c.Request.ContentLength == 0) {
+		return c.bindQuery(v)
+	}
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrUnsupportedMediaType, ct)
+	}
+	switch {
+	case mediaType == MIMEApplicationJSON || strings.HasSuffix(mediaType, "+json"):
+		return c.bindJSON(v)
+	case mediaType == MIMEApplicationXML || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return c.bindXML(v)
+	case mediaType == MIMEApplicationHTMLForm:
+		return c.bindForm(v)
+	case mediaType == "multipart/form-data":
+		return c.bindMultipart(v)
+	}
+	return fmt.Errorf("%w: %s", ErrUnsupportedMediaType, mediaType)
+}
+
+// BindQuery 按 `query:"name"` 标签绑定查询字符串
+func (c *HTTPContext) BindQuery(v any) error {
+	c.alive()
+	return validate(v, c.bindQuery(v))
+}
+
+func (c *HTTPContext) bindQuery(v any) error {
+	q := c.Request.URL.Query()
+	return bindValues(v, "query", func(k string) ([]string, bool) {
+		s, ok := q[k]
+		return s, ok
+	})
+}
+
+// BindForm 按 `form:"name"` 标签绑定表单，含查询字符串
+func (c *HTTPContext) BindForm(v any) error {
+	c.alive()
+	return validate(v, c.bindForm(v))
+}
+
+func (c *HTTPContext) bindForm(v any) error {
+	if err := c.Request.ParseForm(); err != nil {
+		return fmt.Errorf("parse form failed: %w", err)
+	}
+	return bindValues(v, "form", func(k string) ([]string, bool) {
+		s, ok := c.Request.Form[k]
+		return s, ok
+	})
+}
+
+// BindMultipart 按 `form:"name"` 标签绑定 multipart 表单，
+// *multipart.FileHeader 与 []*multipart.FileHeader 类型的字段绑定上传的文件
+func (c *HTTPContext) BindMultipart(v any) error {
+	c.alive()
+	return validate(v, c.bindMultipart(v))
+}
+
+func (c *HTTPContext) bindMultipart(v any) error {
+	if err := c.Request.ParseMultipartForm(DefaultMultipartMemory); err != nil {
+		return fmt.Errorf("parse multipart form failed: %w", err)
+	}
+	form := c.Request.MultipartForm
+	err := bindValues(v, "form", func(k string) ([]string, bool) {
+		s, ok := form.Value[k]
+		if !ok {
+			s, ok = c.Request.Form[k]
+		}
+		return s, ok
+	})
+	if err != nil {
+		return err
+	}
+	return bindFiles(v, form.File)
+}
+
+// BindXML 绑定XML数据
+func (c *HTTPContext) BindXML(v any) error {
+	c.alive()
+	return validate(v, c.bindXML(v))
+}
+
+func (c *HTTPContext) bindXML(v any) error {
+	defer c.Request.Body.Close()
+	if err := xml.NewDecoder(c.Request.Body).Decode(v); err != nil {
+		return fmt.Errorf("decode xml failed: %w", err)
+	}
+	return nil
+}
+
+// BindPath 按 `path:"name"` 标签绑定路径参数
+func (c *HTTPContext) BindPath(v any) error {
+	c.alive()
+	return validate(v, c.bindPath(v))
+}
+
+func (c *HTTPContext) bindPath(v any) error {
+	return bindValues(v, "path", func(k string) ([]string, bool) {
+		s := c.Request.PathValue(k)
+		if len(s) == 0 {
+			return nil, false
+		}
+		return []string{s}, true
+	})
+}
+
+// BindHeader 按 `header:"X-Token"` 标签绑定请求头
+func (c *HTTPContext) BindHeader(v any) error {
+	c.alive()
+	return validate(v, c.bindHeader(v))
+}
+
+func (c *HTTPContext) bindHeader(v any) error {
+	return bindValues(v, "header", func(k string) ([]string, bool) {
+		s := c.Request.Header.Values(k)
+		return s, len(s) > 0
+	})
+}
+
+// structValue 取出指向结构体的指针
+func structValue(v any) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return reflect.Value{}, fmt.Errorf("bind target must be a non-nil pointer, got %T", v)
+	}
+	rv = rv.Elem()
+	if rv.Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("bind target must point to a struct, got %T", v)
+	}
+	return rv, nil
+}
+
+// bindValues 按标签将取值赋给字段，缺失时使用 `default:"..."`，切片的默认值以逗号分隔
+func bindValues(v any, tag string, get func(string) ([]string, bool)) error {
+	rv, err := structValue(v)
+	if err != nil {
+		return err
+	}
+	return bindStruct(rv, tag, get)
+}
+
+func bindStruct(rv reflect.Value, tag string, get func(string) ([]string, bool)) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		fv := rv.Field(i)
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			if err := bindStruct(fv, tag, get); err != nil {
+				return err
+			}
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		key, _, _ := strings.Cut(sf.Tag.Get(tag), ",")
+		if len(key) == 0 || key == "-" {
+			continue
+		}
+		values, ok := get(key)
+		if !ok {
+			def, has := sf.Tag.Lookup("default")
+			if !has {
+				continue
+			}
+			values = []string{def}
+			if fv.Kind() == reflect.Slice {
+				values = strings.Split(def, ",")
+			}
+		}
+		if err := setField(fv, values); err != nil {
+			return &BindError{Field: sf.Name, Source: tag, Key: key, Value: strings.Join(values, ","), Err: err}
+		}
+	}
+	return nil
+}
+
+var (
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+	timeType            = reflect.TypeFor[time.Time]()
+	durationType        = reflect.TypeFor[time.Duration]()
+)
+
+// setField 切片接收全部取值，其余类型取第一个
+func setField(fv reflect.Value, values []string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	if fv.Kind() == reflect.Slice && !fv.Addr().Type().Implements(textUnmarshalerType) {
+		s := reflect.MakeSlice(fv.Type(), len(values), len(values))
+		for i, v := range values {
+			if err := setValue(s.Index(i), v); err != nil {
+				return err
+			}
+		}
+		fv.Set(s)
+		return nil
+	}
+	return setValue(fv, values[0])
+}
+
+func setValue(fv reflect.Value, s string) error {
+	if fv.Kind() == reflect.Pointer {
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		return setValue(fv.Elem(), s)
+	}
+	if fv.CanAddr() && fv.Addr().Type().Implements(textUnmarshalerType) {
+		return fv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	switch fv.Type() {
+	case timeType:
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(t))
+		return nil
+	case durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(s)
+	case reflect.Bool:
+		if len(s) == 0 {
+			fv.SetBool(false)
+			return nil
+		}
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported field type %s", fv.Type())
+	}
+	return nil
+}
+
+var (
+	fileHeaderType      = reflect.TypeFor[*multipart.FileHeader]()
+	fileHeaderSliceType = reflect.TypeFor[[]*multipart.FileHeader]()
+)
+
+// bindFiles 绑定上传的文件
+func bindFiles(v any, files map[string][]*multipart.FileHeader) error {
+	rv, err := structValue(v)
+	if err != nil {
+		return err
+	}
+	return bindFileStruct(rv, files)
+}
+
+func bindFileStruct(rv reflect.Value, files map[string][]*multipart.FileHeader) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			if err := bindFileStruct(rv.Field(i), files); err != nil {
+				return err
+			}
+			continue
+		}
+		key, _, _ := strings.Cut(sf.Tag.Get("form"), ",")
+		if !sf.IsExported() || len(key) == 0 || key == "-" {
+			continue
+		}
+		fh := files[key]
+		if len(fh) == 0 {
+			continue
+		}
+		switch sf.Type {
+		case fileHeaderType:
+			rv.Field(i).Set(reflect.ValueOf(fh[0]))
+		case fileHeaderSliceType:
+			rv.Field(i).Set(reflect.ValueOf(fh))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package whttp
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type bindQuery struct {
+	Page    int           `query:"page" default:"1"`
+	Size    uint8         `query:"size" default:"20"`
+	Tags    []string      `query:"tag"`
+	IDs     []int64       `query:"id" default:"7,8"`
+	Ratio   *float64      `query:"ratio"`
+	Active  bool          `query:"active"`
+	Timeout time.Duration `query:"timeout"`
+	Ignored string
+}
+
+func TestBindQuery(t *testing.T) {
+	r := NewRoute(nil)
+	var got bindQuery
+	var bindErr error
+	r.GET("/", func(c *HTTPContext) {
+		got = bindQuery{}
+		bindErr = c.BindQuery(&got)
+	})
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/?tag=a&tag=b&ratio=0.5&active=true&timeout=2s&Ignored=x", nil))
+	if bindErr != nil {
+		t.Fatal(bindErr)
+	}
+	if got.Page != 1 || got.Size != 20 || len(got.Tags) != 2 || got.Tags[1] != "b" || len(got.IDs) != 2 || got.IDs[1] != 8 ||
+		got.Ratio == nil || *got.Ratio != 0.5 || !got.Active || got.Timeout != 2*time.Second || got.Ignored != "" {
+		t.Errorf("unexpected %+v", got)
+	}
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/?size=300", nil))
+	var be *BindError
+	if !errors.As(bindErr, &be) || be.Field != "Size" || be.Source != "query" {
+		t.Errorf("got %v | expected BindError on Size", bindErr)
+	}
+}
+
+type bindUser struct {
+	ID    int    `path:"id" json:"-" xml:"-"`
+	Token string `header:"X-Token" json:"-" xml:"-"`
+	Name  string `form:"name" json:"name" xml:"name"`
+	Age   int    `form:"age" json:"age" xml:"age"`
+}
+
+func TestBind(t *testing.T) {
+	r := NewRoute(nil)
+	var got bindUser
+	var bindErr error
+	r.POST("/users/{id}", func(c *HTTPContext) {
+		got = bindUser{}
+		bindErr = c.Bind(&got)
+		if bindErr == nil {
+			bindErr = c.BindHeader(&got)
+		}
+	})
+	tests := []struct {
+		contentType string
+		body        string
+	}{
+		{"application/json", `{"name":"linda","age":18}`},
+		{"application/xml", `<bindUser><name>linda</name><age>18</age></bindUser>`},
+		{"application/x-www-form-urlencoded", "name=linda&age=18"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/users/42", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", tt.contentType)
+		req.Header.Set("X-Token", "secret")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+		if bindErr != nil {
+			t.Fatal(tt.contentType, bindErr)
+		}
+		if got.ID != 42 || got.Token != "secret" || got.Name != "linda" || got.Age != 18 {
+			t.Errorf("%s unexpected %+v", tt.contentType, got)
+		}
+	}
+	req := httptest.NewRequest("POST", "/users/42", strings.NewReader("x"))
+	req.Header.Set("Content-Type", "text/plain")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	if !errors.Is(bindErr, ErrUnsupportedMediaType) {
+		t.Errorf("got %v | expected ErrUnsupportedMediaType", bindErr)
+	}
+}
+
+func TestBindMultipart(t *testing.T) {
+	type upload struct {
+		Title string                  `form:"title"`
+		File  *multipart.FileHeader   `form:"file"`
+		All   []*multipart.FileHeader `form:"file"`
+	}
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("title", "report")
+	fw, err := mw.CreateFormFile("file", "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write([]byte("a"))
+	mw.Close()
+	r := NewRoute(nil)
+	var got upload
+	var bindErr error
+	r.POST("/", func(c *HTTPContext) { bindErr = c.Bind(&got) })
+	req := httptest.NewRequest("POST", "/", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	if bindErr != nil {
+		t.Fatal(bindErr)
+	}
+	if got.Title != "report" || got.File == nil || got.File.Filename != "a.txt" || len(got.All) != 1 {
+		t.Errorf("unexpected %+v", got)
+	}
+}
@@ -0,0 +1,190 @@
+package whttp
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// BodyLimitMiddleware 路由级请求体最大字节数，覆盖 SetMaxBodySize 的全局设置，
+// Content-Length 超出时直接返回 413，分块传输时读取超出的部分返回 *http.MaxBytesError
+func BodyLimitMiddleware(n int64) func(*HTTPContext) {
+	if n <= 0 {
+		panic("bodyLimitMiddleware: limit must be positive")
+	}
+	return func(c *HTTPContext) {
+		if c.Request.ContentLength > n {
+			c.Warn("request body too large", "content_length", c.Request.ContentLength, "limit", n)
+			c.String(http.StatusRequestEntityTooLarge, "request entity too large")
+			return
+		}
+		body := c.body
+		if body == nil {
+			body = c.Request.Body
+		}
+		if body != nil && body != http.NoBody {
+			// 传入底层的 ResponseWriter，超出时 net/http 才会在响应后关闭连接
+			c.Request.Body = http.MaxBytesReader(c.rw.ResponseWriter, body, n)
+		}
+		c.Next()
+	}
+}
+
+// DecompressMiddleware 按 Content-Encoding 解压 gzip、deflate 请求体，解压后超出 n 字节时读取返回 *http.MaxBytesError，
+// 防止压缩炸弹；不支持的编码返回 415，压缩格式错误返回 400
+func DecompressMiddleware(n int64) func(*HTTPContext) {
+	if n <= 0 {
+		panic("decompressMiddleware: limit must be positive")
+	}
+	return func(c *HTTPContext) {
+		var encodings []string
+		for _, v := range c.Request.Header.Values("Content-Encoding") {
+			for _, e := range strings.Split(v, ",") {
+				if e = strings.ToLower(strings.TrimSpace(e)); len(e) > 0 && e != "identity" {
+					encodings = append(encodings, e)
+				}
+			}
+		}
+		body := c.Request.Body
+		if len(encodings) == 0 || body == nil || body == http.NoBody {
+			c.Next()
+			return
+		}
+		for _, e := range encodings {
+			if e != "gzip" && e != "x-gzip" && e != "deflate" {
+				c.Writer.Header().Set("Accept-Encoding", "gzip, deflate")
+				c.AbortWithError(http.StatusUnsupportedMediaType, fmt.Errorf("%w: content encoding %s", ErrUnsupportedMediaType, e))
+				return
+			}
+		}
+		d := &decompressBody{Reader: body, body: body}
+		// 按编码应用顺序的逆序解压
+		for i := len(encodings) - 1; i >= 0; i-- {
+			if err := d.wrap(encodings[i]); err != nil {
+				d.Close()
+				c.AbortWithError(http.StatusBadRequest, fmt.Errorf("decompress request body: %w", err))
+				return
+			}
+		}
+		c.Request.Header.Del("Content-Encoding")
+		c.Request.Header.Del("Content-Length")
+		c.Request.ContentLength = -1
+		c.Request.Body = http.MaxBytesReader(c.rw.ResponseWriter, d, n)
+		// 其后的 BodyLimitMiddleware 限制解压后的数据
+		c.body = c.Request.Body
+		c.Next()
+	}
+}
+
+// decompressBody 解压后的请求体，Close 时关闭各层解压器及原始请求体
+type decompressBody struct {
+	io.Reader
+	closers []io.Closer
+	body    io.ReadCloser
+}
+
+// wrap 在当前数据之上叠加一层解压
+func (d *decompressBody) wrap(encoding string) error {
+	if encoding == "deflate" {
+		// HTTP 的 deflate 应为 zlib 格式，兼容部分客户端发送的原始 deflate 数据
+		br := bufio.NewReader(d.Reader)
+		if h, err := br.Peek(2); err == nil && h[0]&0x0f == 8 && (uint16(h[0])<<8|uint16(h[1]))%31 == 0 {
+			zr, err := zlib.NewReader(br)
+			if err != nil {
+				return err
+			}
+			d.Reader = zr
+			d.closers = append(d.closers, zr)
+			return nil
+		}
+		fr := flate.NewReader(br)
+		d.Reader = fr
+		d.closers = append(d.closers, fr)
+		return nil
+	}
+	zr, err := gzip.NewReader(d.Reader)
+	if err != nil {
+		return err
+	}
+	d.Reader = zr
+	d.closers = append(d.closers, zr)
+	return nil
+}
+
+func (d *decompressBody) Close() error {
+	for i := len(d.closers) - 1; i >= 0; i-- {
+		d.closers[i].Close()
+	}
+	return d.body.Close()
+}
+
+// jsonUnmarshal DefaultUnmarshal 的初始值
+var jsonUnmarshal = reflect.ValueOf(json.Unmarshal).Pointer()
+
+// decodeJSON 流式解码，strict 时拒绝未知字段及多余的数据；
+// 非严格模式下 DefaultUnmarshal 被替换时读取完整的请求体后交给它解码
+func decodeJSON(r io.ReadCloser, v any, strict bool) error {
+	if r == nil || r == http.NoBody {
+		return errors.New("bind json: request body is empty")
+	}
+	defer r.Close()
+	if !strict && DefaultUnmarshal != nil && reflect.ValueOf(DefaultUnmarshal).Pointer() != jsonUnmarshal {
+		data, err := io.ReadAll(r)
+		if err == nil {
+			err = DefaultUnmarshal(data, v)
+		}
+		if err != nil {
+			return jsonError(err)
+		}
+		return nil
+	}
+	dec := json.NewDecoder(r)
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(v); err != nil {
+		return jsonError(err)
+	}
+	if strict {
+		if _, err := dec.Token(); err != io.EOF {
+			if err != nil {
+				return jsonError(err)
+			}
+			return errors.New("bind json: body must contain a single JSON value")
+		}
+	}
+	return nil
+}
+
+// jsonError 说明出错的位置或字段，不输出目标对象的内容
+func jsonError(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var maxErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxErr):
+		return fmt.Errorf("bind json: request body exceeds %d bytes: %w", maxErr.Limit, err)
+	case errors.As(err, &syntaxErr):
+		return fmt.Errorf("bind json: malformed json at offset %d: %w", syntaxErr.Offset, err)
+	case errors.As(err, &typeErr):
+		if len(typeErr.Field) > 0 {
+			return fmt.Errorf("bind json: field %s expects %s, got %s: %w", typeErr.Field, typeErr.Type, typeErr.Value, err)
+		}
+		return fmt.Errorf("bind json: expects %s, got %s: %w", typeErr.Type, typeErr.Value, err)
+	case errors.Is(err, io.EOF):
+		return fmt.Errorf("bind json: request body is empty: %w", err)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return fmt.Errorf("bind json: unexpected end of body: %w", err)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return fmt.Errorf("bind json: unknown field %s: %w", strings.TrimPrefix(err.Error(), "json: unknown field "), err)
+	}
+	return fmt.Errorf("bind json: %w", err)
+}
@@ -0,0 +1,246 @@
+package whttp
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// chunked 隐藏 Content-Length，模拟分块传输
+type chunked struct{ io.Reader }
+
+func TestBodyLimit(t *testing.T) {
+	r := NewRoute(nil)
+	r.SetMaxBodySize(8)
+	var readErr error
+	read := func(c *HTTPContext) {
+		_, readErr = io.ReadAll(c.Request.Body)
+		if readErr != nil {
+			c.String(http.StatusRequestEntityTooLarge, readErr.Error())
+			return
+		}
+		c.String(http.StatusOK, "ok")
+	}
+	r.POST("/global", read)
+	r.Group("/upload", BodyLimitMiddleware(16)).POST("/", read)
+	tests := []struct {
+		path   string
+		body   string
+		hidden bool
+		status int
+	}{
+		{"/global", "12345678", false, http.StatusOK},
+		{"/global", "123456789", true, http.StatusRequestEntityTooLarge},
+		{"/upload/", "1234567890123456", false, http.StatusOK},
+		{"/upload/", "12345678901234567", false, http.StatusRequestEntityTooLarge},
+		{"/upload/", "12345678901234567", true, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		var body io.Reader = strings.NewReader(tt.body)
+		if tt.hidden {
+			body = chunked{body}
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("POST", tt.path, body))
+		if rec.Code != tt.status {
+			t.Errorf("%s %q: got %d | expected %d", tt.path, tt.body, rec.Code, tt.status)
+		}
+	}
+	var maxErr *http.MaxBytesError
+	if !errors.As(readErr, &maxErr) || maxErr.Limit != 16 {
+		t.Errorf("got %v | expected MaxBytesError limit 16", readErr)
+	}
+	// 全局设置不预先检查 Content-Length，WrapError 按 *http.MaxBytesError 返回 413
+	r.POST("/wrap", WrapError(func(c *HTTPContext) error {
+		var v map[string]string
+		return c.BindJSON(&v)
+	}))
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("POST", "/wrap", strings.NewReader(`{"name":"abcdef"}`)))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("got %d | expected 413", rec.Code)
+	}
+}
+
+func TestBodyLimitCloseConnection(t *testing.T) {
+	r := NewRoute(nil)
+	read := func(c *HTTPContext) {
+		if _, err := io.ReadAll(c.Request.Body); err != nil {
+			c.String(http.StatusRequestEntityTooLarge, "too large")
+			return
+		}
+		c.String(http.StatusOK, "ok")
+	}
+	r.POST("/limit", BodyLimitMiddleware(4), read)
+	r.POST("/decompress", DecompressMiddleware(4), read)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte("123456789"))
+	zw.Close()
+	for path, body := range map[string][]byte{"/limit": []byte("123456789"), "/decompress": gz.Bytes()} {
+		req, _ := http.NewRequest("POST", ts.URL+path, chunked{bytes.NewReader(body)})
+		if path == "/decompress" {
+			req.Header.Set("Content-Encoding", "gzip")
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		// 超出限制后 net/http 关闭连接
+		if resp.StatusCode != http.StatusRequestEntityTooLarge || !resp.Close {
+			t.Errorf("%s: got %d close %v | expected 413 close true", path, resp.StatusCode, resp.Close)
+		}
+	}
+}
+
+func TestBindJSONErrors(t *testing.T) {
+	type item struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	}
+	r := NewRoute(nil)
+	var bindErr error
+	r.POST("/", func(c *HTTPContext) {
+		var v item
+		bindErr = c.BindJSON(&v)
+	})
+	r.POST("/strict", func(c *HTTPContext) {
+		var v item
+		bindErr = c.BindJSONStrict(&v)
+	})
+	tests := []struct {
+		path   string
+		body   string
+		expect string
+	}{
+		{"/", `{"name":"a","age":1,"x":2}`, ""},
+		{"/", `{"name":"a","age":"1"}`, "field age expects int, got string"},
+		{"/", `{"name":`, "unexpected end of body"},
+		{"/", `{"name" 1}`, "malformed json at offset"},
+		{"/", ``, "request body is empty"},
+		{"/strict", `{"name":"a","x":2}`, `unknown field "x"`},
+		{"/strict", `{"name":"a"}{}`, "single JSON value"},
+		{"/strict", `{"name":"a"}  `, ""},
+	}
+	for _, tt := range tests {
+		bindErr = nil
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", tt.path, strings.NewReader(tt.body)))
+		if tt.expect == "" && bindErr != nil || tt.expect != "" && (bindErr == nil || !strings.Contains(bindErr.Error(), tt.expect)) {
+			t.Errorf("%s %s: got %v | expected %s", tt.path, tt.body, bindErr, tt.expect)
+		}
+	}
+	r.SetStrictJSON(true)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader(`{"x":1}`)))
+	if bindErr == nil {
+		t.Error("got nil | expected unknown field error")
+	}
+	r.SetMaxBodySize(4)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", chunked{strings.NewReader(`{"name":"abcdef"}`)}))
+	var maxErr *http.MaxBytesError
+	if !errors.As(bindErr, &maxErr) {
+		t.Errorf("got %v | expected MaxBytesError", bindErr)
+	}
+}
+
+func TestBindJSONDefaultUnmarshal(t *testing.T) {
+	old := DefaultUnmarshal
+	defer func() { DefaultUnmarshal = old }()
+	var got []byte
+	DefaultUnmarshal = func(data []byte, v any) error {
+		got = data
+		return json.Unmarshal(data, v)
+	}
+	r := NewRoute(nil)
+	r.SetMaxBodySize(16)
+	var bindErr error
+	var v struct {
+		Name string `json:"name"`
+	}
+	r.POST("/", func(c *HTTPContext) {
+		bindErr = c.BindJSON(&v)
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader(`{"name":"a"}`)))
+	if bindErr != nil || v.Name != "a" || string(got) != `{"name":"a"}` {
+		t.Errorf("got %v %s %s | expected a via DefaultUnmarshal", bindErr, v.Name, got)
+	}
+	// 替换后仍受请求体大小限制
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", chunked{strings.NewReader(`{"name":"abcdefghijk"}`)}))
+	var maxErr *http.MaxBytesError
+	if !errors.As(bindErr, &maxErr) {
+		t.Errorf("got %v | expected MaxBytesError", bindErr)
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	compress := func(w io.WriteCloser, buf *bytes.Buffer, data string) []byte {
+		w.Write([]byte(data))
+		w.Close()
+		return buf.Bytes()
+	}
+	gz := func(data string) []byte {
+		var buf bytes.Buffer
+		return compress(gzip.NewWriter(&buf), &buf, data)
+	}
+	zl := func(data string) []byte {
+		var buf bytes.Buffer
+		return compress(zlib.NewWriter(&buf), &buf, data)
+	}
+	raw := func(data string) []byte {
+		var buf bytes.Buffer
+		fw, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+		return compress(fw, &buf, data)
+	}
+	r := NewRoute(nil)
+	r.POST("/", DecompressMiddleware(64), WrapError(func(c *HTTPContext) error {
+		var v struct {
+			Name string `json:"name"`
+		}
+		if err := c.BindJSON(&v); err != nil {
+			return err
+		}
+		c.String(http.StatusOK, v.Name+" "+c.Request.Header.Get("Content-Encoding"))
+		return nil
+	}))
+	bomb := `{"name":"` + strings.Repeat("a", 1<<20) + `"}`
+	tests := []struct {
+		encoding string
+		body     []byte
+		status   int
+		expect   string
+	}{
+		{"", []byte(`{"name":"plain"}`), http.StatusOK, "plain "},
+		{"gzip", gz(`{"name":"gzip"}`), http.StatusOK, "gzip "},
+		{"deflate", zl(`{"name":"zlib"}`), http.StatusOK, "zlib "},
+		{"deflate", raw(`{"name":"raw"}`), http.StatusOK, "raw "},
+		{"deflate, gzip", gz(string(zl(`{"name":"both"}`))), http.StatusOK, "both "},
+		{"gzip", gz(bomb), http.StatusRequestEntityTooLarge, ""},
+		{"gzip", []byte("not gzip"), http.StatusBadRequest, ""},
+		{"br", []byte("x"), http.StatusUnsupportedMediaType, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/", bytes.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		if len(tt.encoding) > 0 {
+			req.Header.Set("Content-Encoding", tt.encoding)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != tt.status || tt.status == http.StatusOK && rec.Body.String() != tt.expect {
+			t.Errorf("%s: got %d %s | expected %d %s", tt.encoding, rec.Code, rec.Body.String(), tt.status, tt.expect)
+		}
+		if tt.status == http.StatusUnsupportedMediaType && rec.Header().Get("Accept-Encoding") != "gzip, deflate" {
+			t.Errorf("got Accept-Encoding %q | expected gzip, deflate", rec.Header().Get("Accept-Encoding"))
+		}
+	}
+}
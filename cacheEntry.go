@@ -0,0 +1,148 @@
+package whttp
+
+import (
+	"encoding/binary"
+	"net/http"
+	"time"
+)
+
+// cacheEntry 缓存的响应，或记录参与缓存键的请求头的 Vary 索引
+type cacheEntry struct {
+	status int
+	// 存入的时间
+	stored time.Time
+	// 过期的时间，零值不过期
+	expires time.Time
+	// 过期后可直接返回并在后台更新的截止时间
+	revalidate time.Time
+	// 过期后生成响应出错时可返回的截止时间
+	ifError time.Time
+	header  http.Header
+	body    []byte
+	// 非空时为 Vary 索引
+	vary []string
+}
+
+const (
+	cacheEntryVersion = 2
+	cacheKindResponse = 0
+	cacheKindVary     = 1
+)
+
+// fresh 在 now 时是否新鲜
+func (e *cacheEntry) fresh(now time.Time) bool {
+	return e.expires.IsZero() || now.Before(e.expires)
+}
+
+// encode 编码为存入 Cache 的字节
+func (e *cacheEntry) encode() []byte {
+	b := []byte{cacheEntryVersion, cacheKindResponse}
+	if len(e.vary) > 0 {
+		b[1] = cacheKindVary
+		b = binary.AppendUvarint(b, uint64(len(e.vary)))
+		for _, v := range e.vary {
+			b = appendString(b, v)
+		}
+		return b
+	}
+	b = binary.AppendUvarint(b, uint64(e.status))
+	b = binary.AppendVarint(b, e.stored.UnixNano())
+	for _, t := range []time.Time{e.expires, e.revalidate, e.ifError} {
+		var n int64
+		if !t.IsZero() {
+			n = t.UnixNano()
+		}
+		b = binary.AppendVarint(b, n)
+	}
+	b = binary.AppendUvarint(b, uint64(len(e.header)))
+	for k, vs := range e.header {
+		b = appendString(b, k)
+		b = binary.AppendUvarint(b, uint64(len(vs)))
+		for _, v := range vs {
+			b = appendString(b, v)
+		}
+	}
+	return append(b, e.body...)
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// decodeCacheEntry 解码 encode 的结果，格式错误时返回 false
+func decodeCacheEntry(b []byte) (e cacheEntry, ok bool) {
+	if len(b) < 2 || b[0] != cacheEntryVersion {
+		return e, false
+	}
+	kind := b[1]
+	d := cacheDecoder{b: b[2:]}
+	if kind == cacheKindVary {
+		n := d.uvarint()
+		for i := uint64(0); i < n && d.ok(); i++ {
+			e.vary = append(e.vary, d.string())
+		}
+		return e, d.ok() && len(e.vary) > 0
+	}
+	e.status = int(d.uvarint())
+	e.stored = time.Unix(0, d.varint())
+	for _, t := range []*time.Time{&e.expires, &e.revalidate, &e.ifError} {
+		if n := d.varint(); n != 0 {
+			*t = time.Unix(0, n)
+		}
+	}
+	n := d.uvarint()
+	e.header = make(http.Header, min(n, 64))
+	for i := uint64(0); i < n && d.ok(); i++ {
+		k := d.string()
+		m := d.uvarint()
+		vs := make([]string, 0, min(m, 16))
+		for j := uint64(0); j < m && d.ok(); j++ {
+			vs = append(vs, d.string())
+		}
+		e.header[k] = vs
+	}
+	e.body = d.b
+	return e, d.ok() && kind == cacheKindResponse
+}
+
+// cacheDecoder 按顺序读取，出错后各方法返回零值
+type cacheDecoder struct {
+	b   []byte
+	err bool
+}
+
+func (d *cacheDecoder) ok() bool {
+	return !d.err
+}
+
+func (d *cacheDecoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.err = true
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *cacheDecoder) varint() int64 {
+	v, n := binary.Varint(d.b)
+	if n <= 0 {
+		d.err = true
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *cacheDecoder) string() string {
+	n := d.uvarint()
+	if d.err || n > uint64(len(d.b)) {
+		d.err = true
+		return ""
+	}
+	s := string(d.b[:n])
+	d.b = d.b[n:]
+	return s
+}
@@ -2,7 +2,15 @@ package whttp
 
 import (
 	"bytes"
+	"context"
+	"io"
+	"maps"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 // Cache 缓存接口
@@ -12,24 +20,492 @@ type Cache interface {
 	HasGet(dst []byte, key string) ([]byte, bool)
 }
 
+// CacheMaxBodySize CacheMiddleware 缓存响应体的上限，超出或中途刷新时不缓存
+var CacheMaxBodySize = 1 << 20
+
+// CacheConfig 缓存配置
+type CacheConfig struct {
+	Cache Cache
+	// 命中缓存时额外设置的响应头
+	Header map[string]string
+	// 缓存的请求方法，空时为 GET、HEAD
+	Methods []string
+	// 缓存的状态码，空时为 DefaultCacheStatuses；
+	// 200 以外的状态码仅在响应指定 s-maxage、max-age、Expires 或 TTL 大于 0 时缓存
+	Statuses []int
+	// 响应未指定 s-maxage、max-age、Expires 时的缓存时间，0 时不过期，小于 0 时不缓存
+	TTL time.Duration
+	// 缓存响应体的上限，0 时使用 CacheMaxBodySize
+	MaxBodySize int
+	// 响应未指定 stale-while-revalidate、stale-if-error 时的缺省值
+	StaleWhileRevalidate time.Duration
+	StaleIfError         time.Duration
+	// 存入时附加的标签，Cache 实现 ExtendedCache 时可经 InvalidateTag 按标签删除
+	Tags func(*HTTPContext) []string
+}
+
+// DefaultCacheStatuses CacheConfig.Statuses 为空时缓存的状态码
+var DefaultCacheStatuses = []int{http.StatusOK}
+
+// CacheMiddleware 缓存响应，header 为命中缓存时额外设置的响应头
 func CacheMiddleware(cache Cache, header map[string]string) func(*HTTPContext) {
-	return func(c *HTTPContext) {
-		key := c.Request.URL.RequestURI()
-		data, ok := cache.HasGet(nil, key)
-		if ok {
-			for k, v := range header {
-				c.Writer.Header().Set(k, v)
-			}
-			c.write(http.StatusOK, data)
+	return CacheMiddlewareWithConfig(CacheConfig{Cache: cache, Header: header})
+}
+
+// CacheMiddlewareWithConfig 按 HTTP 语义缓存响应的状态码、响应头与响应体，命中时添加 Age。
+// 请求的 Cache-Control 含 no-store 时不使用缓存，含 no-cache 或 max-age 超出时重新生成；
+// 响应的 Cache-Control 含 no-store、private、no-cache，带有 Set-Cookie 或 Vary: * 时不缓存，
+// 新鲜期依次取 s-maxage、max-age、Expires、TTL；缓存键包含 Vary 列出的请求头。
+// 同一键的并发未命中只执行一次处理函数，其余请求等待后读取缓存；
+// 过期的响应在 stale-while-revalidate 期间直接返回并在后台更新，在 stale-if-error 期间于生成出错时返回
+func CacheMiddlewareWithConfig(cfg CacheConfig) func(*HTTPContext) {
+	if cfg.Cache == nil {
+		panic("cacheMiddleware: cache cannot be nil")
+	}
+	hc := &httpCache{CacheConfig: cfg, flights: make(map[string]chan struct{})}
+	if len(hc.Methods) == 0 {
+		hc.Methods = []string{http.MethodGet, http.MethodHead}
+	}
+	if len(hc.Statuses) == 0 {
+		hc.Statuses = DefaultCacheStatuses
+	}
+	if hc.MaxBodySize == 0 {
+		hc.MaxBodySize = CacheMaxBodySize
+	}
+	return hc.serve
+}
+
+// httpCache CacheMiddlewareWithConfig 的状态
+type httpCache struct {
+	CacheConfig
+	// 正在生成响应的键，完成时关闭
+	mu      sync.Mutex
+	flights map[string]chan struct{}
+}
+
+func (hc *httpCache) serve(c *HTTPContext) {
+	if !slices.Contains(hc.Methods, c.Request.Method) {
+		c.Next()
+		return
+	}
+	reqCC := parseCacheControl(c.Request.Header.Values("Cache-Control"))
+	if _, ok := reqCC["no-store"]; ok {
+		c.Next()
+		return
+	}
+	key := c.Request.Method + " " + c.Request.Host + c.Request.URL.RequestURI()
+	if _, ok := reqCC["no-cache"]; ok {
+		hc.run(c, key)
+		return
+	}
+	// acceptable 满足请求 max-age 的缓存
+	acceptable := func(e *cacheEntry, now time.Time) bool {
+		maxAge, err := strconv.Atoi(reqCC["max-age"])
+		return err != nil || now.Sub(e.stored) <= time.Duration(maxAge)*time.Second
+	}
+	now := time.Now()
+	if e, ok := cacheLookup(hc.Cache, key, c.Request); ok && acceptable(&e, now) {
+		switch {
+		case e.fresh(now):
+			serveCacheEntry(c, &e, now.Sub(e.stored), hc.Header)
+			return
+		case now.Before(e.revalidate):
+			hc.refresh(c, key)
+			serveCacheEntry(c, &e, now.Sub(e.stored), hc.Header)
+			return
+		case now.Before(e.ifError):
+			hc.fetchOrStale(c, key, &e)
+			return
+		}
+	}
+	done, leader := hc.join(key)
+	if leader {
+		defer hc.leave(key, done)
+		hc.run(c, key)
+		return
+	}
+	select {
+	case <-done:
+	case <-c.Request.Context().Done():
+		return
+	}
+	now = time.Now()
+	if e, ok := cacheLookup(hc.Cache, key, c.Request); ok && e.fresh(now) && acceptable(&e, now) {
+		serveCacheEntry(c, &e, now.Sub(e.stored), hc.Header)
+		return
+	}
+	// 响应不可缓存或 Vary 不同，自行生成
+	hc.run(c, key)
+}
+
+// join 加入 key 的生成，首个加入者为 leader，负责生成后调用 leave
+func (hc *httpCache) join(key string) (done chan struct{}, leader bool) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	if done, ok := hc.flights[key]; ok {
+		return done, false
+	}
+	done = make(chan struct{})
+	hc.flights[key] = done
+	return done, true
+}
+
+func (hc *httpCache) leave(key string, done chan struct{}) {
+	hc.mu.Lock()
+	delete(hc.flights, key)
+	hc.mu.Unlock()
+	close(done)
+}
+
+// run 执行其后的处理函数，处理链返回后即存入缓存
+func (hc *httpCache) run(c *HTTPContext, key string) {
+	authorized := len(c.Request.Header.Get("Authorization")) > 0
+	pool := c.route.pool
+	var cw *cacheWriter
+	c.Transform(func(h http.Header, status int, w io.Writer) io.WriteCloser {
+		if !slices.Contains(hc.Statuses, status) {
+			return nil
+		}
+		// 内层变换可能在写出时才修改响应头，外层变换在收到数据后修改的响应头不应存入，
+		// 故在数据首次经过时复制响应头
+		cw = &cacheWriter{w: w, h: h, buf: pool.AllocBuffer(), free: pool.FreeBuffer, max: hc.MaxBodySize,
+			store: func(h http.Header, b []byte) { hc.store(c, key, status, h, b, authorized) }}
+		return cw
+	})
+	c.Next()
+	// 内层变换与 HookBeforWriteHeader 均已关闭
+	if cw != nil {
+		cw.Close()
+	}
+}
+
+// store 按响应头计算有效期后存入
+func (hc *httpCache) store(c *HTTPContext, key string, status int, h http.Header, body []byte, authorized bool) {
+	now := time.Now()
+	expires, ok := cacheExpires(status, h, now, hc.TTL, authorized)
+	if !ok {
+		return
+	}
+	e := cacheEntry{status: status, stored: now, expires: expires, header: cacheHeader(h), body: body}
+	if !expires.IsZero() {
+		cc := parseCacheControl(h.Values("Cache-Control"))
+		e.revalidate = expires.Add(cacheDirective(cc, "stale-while-revalidate", hc.StaleWhileRevalidate))
+		e.ifError = expires.Add(cacheDirective(cc, "stale-if-error", hc.StaleIfError))
+		if !now.Before(e.revalidate) && !now.Before(e.ifError) {
 			return
 		}
-		f := func(b *bytes.Buffer) *bytes.Buffer {
-			cache.Set(key, b.Bytes())
-			return b
+	}
+	ext, ok := hc.Cache.(ExtendedCache)
+	if !ok {
+		cacheStore(hc.Cache.Set, key, c.Request, h, &e)
+		return
+	}
+	// 保留到过期后仍可返回的时间为止
+	var ttl time.Duration
+	if !expires.IsZero() {
+		ttl = max(expires.Sub(now), e.revalidate.Sub(now), e.ifError.Sub(now))
+	}
+	var tags []string
+	if hc.Tags != nil {
+		tags = hc.Tags(c)
+	}
+	cacheStore(func(k string, v []byte) { ext.SetWithTTL(k, v, ttl, tags...) }, key, c.Request, h, &e)
+}
+
+// cacheDirective 以秒为单位的指令值，缺少或无效时返回 def
+func cacheDirective(cc map[string]string, name string, def time.Duration) time.Duration {
+	if n, err := strconv.ParseInt(cc[name], 10, 64); err == nil && n >= 0 {
+		return time.Duration(n) * time.Second
+	}
+	return def
+}
+
+// fetchContext 复制 c 的处理链位置与键值，在独立的上下文中生成响应，写入 rec
+func fetchContext(c *HTTPContext, req *http.Request, rec *cacheRecorder) *HTTPContext {
+	nc := &HTTPContext{chain: c.chain, index: c.index, route: c.route, Request: req, body: req.Body}
+	c.mu.RLock()
+	nc.keys.Key = slices.Clone(c.keys.Key)
+	nc.keys.Value = slices.Clone(c.keys.Value)
+	nc.values = maps.Clone(c.values)
+	c.mu.RUnlock()
+	nc.rw.reset(rec, nc)
+	nc.Writer = &nc.rw
+	return nc
+}
+
+// fetch 执行 fetchContext 其后的处理函数并存入缓存，处理函数 panic 时返回 false
+func (hc *httpCache) fetch(nc *HTTPContext, key string) (ok bool) {
+	defer func() {
+		if v := recover(); v != nil {
+			nc.Error("cache fetch panic recovered", "error", v)
+			ok = false
 		}
-		c.HookBeforWriteHeader = append(c.HookBeforWriteHeader, f)
-		c.Next()
+	}()
+	hc.run(nc, key)
+	if err := nc.rw.close(); err != nil {
+		nc.Error("cache fetch", "error", err.Error())
+	}
+	return true
+}
+
+// refresh 在后台重新生成过期的响应，同一键只有一个生成
+func (hc *httpCache) refresh(c *HTTPContext, key string) {
+	done, leader := hc.join(key)
+	if !leader {
+		return
+	}
+	req := c.Request.Clone(context.WithoutCancel(c.Request.Context()))
+	req.Body = http.NoBody
+	req.Header.Del("Cache-Control")
+	nc := fetchContext(c, req, newCacheRecorder())
+	go func() {
+		defer hc.leave(key, done)
+		hc.fetch(nc, key)
+	}()
+}
+
+// fetchOrStale 重新生成响应，出错时返回过期的缓存；同一键只有一个生成，
+// 其余请求等待后读取新的缓存，仍无新鲜的缓存时视为出错返回过期的缓存
+func (hc *httpCache) fetchOrStale(c *HTTPContext, key string, e *cacheEntry) {
+	done, leader := hc.join(key)
+	if !leader {
+		select {
+		case <-done:
+		case <-c.Request.Context().Done():
+			return
+		}
+		now := time.Now()
+		if ne, ok := cacheLookup(hc.Cache, key, c.Request); ok && ne.fresh(now) {
+			e = &ne
+		}
+		serveCacheEntry(c, e, now.Sub(e.stored), hc.Header)
+		return
+	}
+	defer hc.leave(key, done)
+	rec := newCacheRecorder()
+	ok := hc.fetch(fetchContext(c, c.Request, rec), key)
+	rec.finish()
+	if !ok || rec.status >= http.StatusInternalServerError {
+		serveCacheEntry(c, e, time.Since(e.stored), hc.Header)
+		return
+	}
+	dst := c.Writer.Header()
+	for k, v := range rec.header {
+		dst[k] = v
+	}
+	c.write(rec.status, rec.body.Bytes())
+}
+
+// cacheRecorder 缓存后台生成的响应
+type cacheRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newCacheRecorder() *cacheRecorder {
+	return &cacheRecorder{header: make(http.Header)}
+}
+
+func (r *cacheRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *cacheRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+func (r *cacheRecorder) Write(b []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	return r.body.Write(b)
+}
+
+// finish 生成结束时调用，处理函数未写入时与 net/http 相同视为 200
+func (r *cacheRecorder) finish() {
+	r.WriteHeader(http.StatusOK)
+}
+
+// serveCacheEntry 写出缓存的响应
+func serveCacheEntry(c *HTTPContext, e *cacheEntry, age time.Duration, header map[string]string) {
+	dst := c.Writer.Header()
+	for k, v := range e.header {
+		dst[k] = v
+	}
+	dst.Set("Age", strconv.FormatInt(int64(age/time.Second), 10))
+	for k, v := range header {
+		dst.Set(k, v)
+	}
+	c.write(e.status, e.body)
+}
+
+// cacheLookup 取得缓存的响应，遇到 Vary 索引时按请求头再次查找
+func cacheLookup(cache Cache, key string, req *http.Request) (e cacheEntry, ok bool) {
+	get := cache.HasGet
+	if lc, isLookup := cache.(lookupCache); isLookup {
+		get = lc.lookup
+		defer func() { lc.record(ok) }()
+	}
+	data, ok := get(nil, key)
+	if !ok {
+		return cacheEntry{}, false
+	}
+	e, ok = decodeCacheEntry(data)
+	if !ok || len(e.vary) == 0 {
+		return e, ok
+	}
+	if data, ok = get(nil, varyKey(key, e.vary, req)); !ok {
+		return cacheEntry{}, false
+	}
+	e, ok = decodeCacheEntry(data)
+	return e, ok && len(e.vary) == 0
+}
+
+// cacheStore 经 set 存入响应，响应带有 Vary 时在 key 下存入索引，响应存入包含请求头的键
+func cacheStore(set func(string, []byte), key string, req *http.Request, h http.Header, e *cacheEntry) {
+	var vary []string
+	for _, v := range h.Values("Vary") {
+		for _, f := range strings.Split(v, ",") {
+			if f = http.CanonicalHeaderKey(strings.TrimSpace(f)); len(f) > 0 && !slices.Contains(vary, f) {
+				vary = append(vary, f)
+			}
+		}
+	}
+	if len(vary) == 0 {
+		set(key, e.encode())
+		return
+	}
+	slices.Sort(vary)
+	index := cacheEntry{vary: vary}
+	set(key, index.encode())
+	set(varyKey(key, vary, req), e.encode())
+}
+
+// varyKey 由 Vary 列出的请求头组成的缓存键
+func varyKey(key string, vary []string, req *http.Request) string {
+	var b strings.Builder
+	b.WriteString(key)
+	for _, f := range vary {
+		b.WriteString("\n")
+		b.WriteString(f)
+		b.WriteString(":")
+		b.WriteString(strings.Join(req.Header.Values(f), ","))
+	}
+	return b.String()
+}
+
+// parseCacheControl 解析 Cache-Control，指令名转为小写
+func parseCacheControl(values []string) map[string]string {
+	cc := make(map[string]string)
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if len(k) > 0 {
+				cc[strings.ToLower(k)] = strings.Trim(v, `"`)
+			}
+		}
+	}
+	return cc
+}
+
+// cacheExpires 按响应头计算过期时间，不可缓存时返回 false；
+// 请求带有 Authorization 时只缓存 public 或 s-maxage 的响应
+func cacheExpires(status int, h http.Header, now time.Time, ttl time.Duration, authorized bool) (time.Time, bool) {
+	cc := parseCacheControl(h.Values("Cache-Control"))
+	for _, d := range []string{"no-store", "private", "no-cache"} {
+		if _, ok := cc[d]; ok {
+			return time.Time{}, false
+		}
+	}
+	if len(h.Values("Set-Cookie")) > 0 || strings.Contains(strings.Join(h.Values("Vary"), ","), "*") {
+		return time.Time{}, false
+	}
+	_, public := cc["public"]
+	_, shared := cc["s-maxage"]
+	if authorized && !public && !shared {
+		return time.Time{}, false
+	}
+	for _, d := range []string{"s-maxage", "max-age"} {
+		if v, ok := cc[d]; ok {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n < 0 {
+				return time.Time{}, false
+			}
+			return now.Add(time.Duration(n) * time.Second), true
+		}
+	}
+	if v := h.Get("Expires"); len(v) > 0 {
+		t, err := http.ParseTime(v)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return t, true
+	}
+	if ttl < 0 {
+		return time.Time{}, false
+	}
+	if ttl == 0 {
+		// 不过期的缓存只用于 200，错误等响应不应一直保留
+		return time.Time{}, status == http.StatusOK
+	}
+	return now.Add(ttl), true
+}
+
+// cacheHeader 复制需要缓存的响应头，去掉逐跳及由服务端生成的头
+func cacheHeader(h http.Header) http.Header {
+	dst := h.Clone()
+	for _, k := range []string{"Connection", "Keep-Alive", "Proxy-Connection", "Transfer-Encoding", "Upgrade", "Trailer", "Date", "Age"} {
+		delete(dst, k)
+	}
+	return dst
+}
+
+// cacheWriter 写出的同时复制响应体，结束时存入缓存
+type cacheWriter struct {
+	w io.Writer
+	h http.Header
+	// 首次写出或关闭时 h 的副本
+	header http.Header
+	buf    *bytes.Buffer
+	free   func(*bytes.Buffer)
+	max    int
+	store  func(http.Header, []byte)
+}
+
+func (cw *cacheWriter) Write(p []byte) (int, error) {
+	if cw.buf != nil && cw.header == nil {
+		cw.header = cw.h.Clone()
+	}
+	n, err := cw.w.Write(p)
+	if cw.buf != nil {
+		if err != nil || cw.buf.Len()+n > cw.max {
+			cw.Flush()
+		} else {
+			cw.buf.Write(p[:n])
+		}
+	}
+	return n, err
+}
+
+// Flush 流式响应不缓存
+func (cw *cacheWriter) Flush() error {
+	if cw.buf != nil {
+		cw.free(cw.buf)
+		cw.buf = nil
+	}
+	return nil
+}
+
+func (cw *cacheWriter) Close() error {
+	if cw.buf != nil {
+		if cw.header == nil {
+			cw.header = cw.h.Clone()
+		}
+		cw.store(cw.header, cw.buf.Bytes())
+		cw.Flush()
 	}
+	return nil
 }
 
 // https://pkg.go.dev/github.com/VictoriaMetrics/fastcache
@@ -6,9 +6,12 @@ import (
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 type SyncMapCache struct {
@@ -112,4 +115,344 @@ Date : [Tue, 05 Aug 2025 07:36:38 GMT]
 Content-Type : [text/html; charset=utf-8]
 Vary : [Accept-Encoding]
 Date : [Tue, 05 Aug 2025 07:36:38 GMT]
-*/
\ No newline at end of file
+*/
+func TestCacheOuterCompress(t *testing.T) {
+	text := strings.Repeat("hello world ", 200)
+	calls := 0
+	r := NewRoute(nil)
+	r.Use(CompressMiddleware(CompressConfig{}), CacheMiddleware(NewMemoryCache(1<<24), nil))
+	r.GET("/", func(c *HTTPContext) {
+		calls++
+		c.Writer.Header().Set("ETag", `"v1"`)
+		c.String(http.StatusOK, text)
+	})
+	for _, accept := range []string{"gzip", "gzip", "", ""} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", accept)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		// 外层压缩在缓存之后修改的响应头不存入缓存
+		var body io.Reader = rec.Body
+		if accept == "gzip" {
+			zr, err := gzip.NewReader(body)
+			if err != nil {
+				t.Fatalf("%q: %v", accept, err)
+			}
+			body = zr
+		}
+		data, err := io.ReadAll(body)
+		if err != nil || string(data) != text || rec.Header().Get("Content-Encoding") != accept {
+			t.Errorf("%q: got %d bytes %v, Content-Encoding %q | expected %d bytes", accept, len(data), err, rec.Header().Get("Content-Encoding"), len(text))
+		}
+		if expected := map[string]string{"gzip": `W/"v1"`, "": `"v1"`}[accept]; rec.Header().Get("ETag") != expected {
+			t.Errorf("%q: got ETag %s | expected %s", accept, rec.Header().Get("ETag"), expected)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("got %d calls | expected 2", calls)
+	}
+}
+
+func TestCacheHTTPSemantics(t *testing.T) {
+	r := NewRoute(nil)
+	var cache SyncMapCache
+	calls := make(map[string]int)
+	r.Use(CacheMiddlewareWithConfig(CacheConfig{Cache: &cache, TTL: time.Minute, Statuses: []int{http.StatusOK, http.StatusNotFound}}))
+	handle := func(status int, header ...string) func(*HTTPContext) {
+		return func(c *HTTPContext) {
+			calls[c.Request.URL.Path]++
+			for i := 0; i < len(header); i += 2 {
+				c.Writer.Header().Set(header[i], header[i+1])
+			}
+			c.String(status, c.Request.Header.Get("Accept-Language")+strconv.Itoa(calls[c.Request.URL.Path]))
+		}
+	}
+	r.GET("/ok", handle(http.StatusOK, "X-Custom", "1"))
+	r.GET("/missing", handle(http.StatusNotFound))
+	r.GET("/error", handle(http.StatusInternalServerError))
+	r.POST("/post", handle(http.StatusOK))
+	r.GET("/no-store", handle(http.StatusOK, "Cache-Control", "no-store"))
+	r.GET("/private", handle(http.StatusOK, "Cache-Control", "private, max-age=60"))
+	r.GET("/expired", handle(http.StatusOK, "Expires", "Mon, 02 Jan 2006 15:04:05 GMT"))
+	r.GET("/cookie", handle(http.StatusOK, "Set-Cookie", "a=1"))
+	r.GET("/vary", handle(http.StatusOK, "Vary", "Accept-Language"))
+	r.GET("/public", handle(http.StatusOK, "Cache-Control", "public, max-age=60"))
+	tests := []struct {
+		method, path string
+		header       []string
+		expect       string
+	}{
+		{"GET", "/ok", nil, "1"},
+		{"GET", "/ok", nil, "1"},
+		{"GET", "/ok", []string{"Cache-Control", "no-cache"}, "2"},
+		{"GET", "/ok", []string{"Cache-Control", "no-store"}, "3"},
+		{"GET", "/ok", nil, "2"},
+		{"GET", "/missing", nil, "1"},
+		{"GET", "/missing", nil, "1"},
+		{"GET", "/error", nil, "1"},
+		{"GET", "/error", nil, "2"},
+		{"POST", "/post", nil, "1"},
+		{"POST", "/post", nil, "2"},
+		{"GET", "/no-store", nil, "1"},
+		{"GET", "/no-store", nil, "2"},
+		{"GET", "/private", nil, "1"},
+		{"GET", "/private", nil, "2"},
+		{"GET", "/expired", nil, "1"},
+		{"GET", "/expired", nil, "2"},
+		{"GET", "/cookie", nil, "1"},
+		{"GET", "/cookie", nil, "2"},
+		{"GET", "/vary", []string{"Accept-Language", "en"}, "en1"},
+		{"GET", "/vary", []string{"Accept-Language", "zh"}, "zh2"},
+		{"GET", "/vary", []string{"Accept-Language", "en"}, "en1"},
+		{"GET", "/ok", []string{"Authorization", "Bearer x", "Cache-Control", "no-cache"}, "4"},
+		{"GET", "/ok", nil, "2"},
+		{"GET", "/public", []string{"Authorization", "Bearer x"}, "1"},
+		{"GET", "/public", nil, "1"},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		for j := 0; j < len(tt.header); j += 2 {
+			req.Header.Set(tt.header[j], tt.header[j+1])
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Body.String() != tt.expect {
+			t.Errorf("%d %s %s %v: got %s | expected %s", i, tt.method, tt.path, tt.header, rec.Body.String(), tt.expect)
+		}
+	}
+	// 命中时保留状态码与响应头，并添加 Age
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/missing", nil))
+	if rec.Code != http.StatusNotFound || rec.Header().Get("Age") != "0" {
+		t.Errorf("got %d Age %q | expected 404 Age 0", rec.Code, rec.Header().Get("Age"))
+	}
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/ok", nil))
+	if rec.Header().Get("X-Custom") != "1" {
+		t.Errorf("got %v | expected X-Custom", rec.Header())
+	}
+	// 缺省只缓存 200，TTL 为 0 时 404 即使列入 Statuses 也需指定有效期
+	var legacy SyncMapCache
+	r = NewRoute(nil)
+	r.GET("/default", CacheMiddleware(&legacy, nil), handle(http.StatusNotFound))
+	r.GET("/ttl0", CacheMiddlewareWithConfig(CacheConfig{Cache: &legacy, Statuses: []int{http.StatusNotFound}}), handle(http.StatusNotFound))
+	r.GET("/max-age", CacheMiddlewareWithConfig(CacheConfig{Cache: &legacy, Statuses: []int{http.StatusNotFound}}),
+		handle(http.StatusNotFound, "Cache-Control", "max-age=60"))
+	for path, expect := range map[string]string{"/default": "2", "/ttl0": "2", "/max-age": "1"} {
+		for range 2 {
+			rec = httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		}
+		if rec.Body.String() != expect {
+			t.Errorf("%s: got %s | expected %s", path, rec.Body.String(), expect)
+		}
+	}
+}
+
+func TestCacheExpires(t *testing.T) {
+	now := time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		status     int
+		header     []string
+		ttl        time.Duration
+		authorized bool
+		expires    time.Duration
+		ok         bool
+	}{
+		{http.StatusOK, nil, 0, false, 0, true},
+		{http.StatusNotFound, nil, 0, false, 0, false},
+		{http.StatusNotFound, nil, time.Minute, false, time.Minute, true},
+		{http.StatusNotFound, []string{"Cache-Control", "max-age=10"}, 0, false, 10 * time.Second, true},
+		{http.StatusOK, nil, time.Minute, false, time.Minute, true},
+		{http.StatusOK, nil, -1, false, 0, false},
+		{http.StatusOK, []string{"Cache-Control", "max-age=10"}, time.Minute, false, 10 * time.Second, true},
+		{http.StatusOK, []string{"Cache-Control", "max-age=10, s-maxage=20"}, 0, false, 20 * time.Second, true},
+		{http.StatusOK, []string{"Cache-Control", "max-age=0"}, time.Minute, false, 0, true},
+		{http.StatusOK, []string{"Cache-Control", "no-cache"}, time.Minute, false, 0, false},
+		{http.StatusOK, []string{"Expires", now.Add(time.Hour).Format(http.TimeFormat)}, time.Minute, false, time.Hour, true},
+		{http.StatusOK, []string{"Vary", "*"}, time.Minute, false, 0, false},
+		{http.StatusOK, nil, time.Minute, true, 0, false},
+		{http.StatusOK, []string{"Cache-Control", "s-maxage=5"}, 0, true, 5 * time.Second, true},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		for i := 0; i < len(tt.header); i += 2 {
+			h.Set(tt.header[i], tt.header[i+1])
+		}
+		expires, ok := cacheExpires(tt.status, h, now, tt.ttl, tt.authorized)
+		var d time.Duration
+		if !expires.IsZero() {
+			d = expires.Sub(now)
+		}
+		if ok != tt.ok || d != tt.expires {
+			t.Errorf("%d %v %v %v: got %v %v | expected %v %v", tt.status, tt.header, tt.ttl, tt.authorized, d, ok, tt.expires, tt.ok)
+		}
+	}
+}
+
+func TestCacheCoalesce(t *testing.T) {
+	r := NewRoute(nil)
+	var cache SyncMapCache
+	var arrived, calls atomic.Int32
+	release := make(chan struct{})
+	r.GET("/", func(c *HTTPContext) {
+		arrived.Add(1)
+		c.Next()
+	}, CacheMiddlewareWithConfig(CacheConfig{Cache: &cache, TTL: time.Minute}), func(c *HTTPContext) {
+		calls.Add(1)
+		<-release
+		c.String(http.StatusOK, "slow")
+	})
+	const n = 10
+	var wg sync.WaitGroup
+	bodies := make([]string, n)
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+			bodies[i] = rec.Body.String()
+		}()
+	}
+	for arrived.Load() < n {
+		time.Sleep(time.Millisecond)
+	}
+	// 等待其余请求进入等待
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if calls.Load() != 1 {
+		t.Errorf("got %d calls | expected 1", calls.Load())
+	}
+	for _, b := range bodies {
+		if b != "slow" {
+			t.Errorf("got %s | expected slow", b)
+		}
+	}
+}
+
+func TestCacheStale(t *testing.T) {
+	r := NewRoute(nil)
+	var cache SyncMapCache
+	var revalidate, ifError atomic.Int32
+	r.Use(CacheMiddlewareWithConfig(CacheConfig{Cache: &cache}))
+	r.GET("/revalidate", func(c *HTTPContext) {
+		n := revalidate.Add(1)
+		c.Writer.Header().Set("Cache-Control", "max-age=0, stale-while-revalidate=60")
+		c.String(http.StatusOK, "v"+strconv.Itoa(int(n)))
+	})
+	r.GET("/error", func(c *HTTPContext) {
+		c.Writer.Header().Set("Cache-Control", "max-age=0, stale-if-error=60")
+		switch ifError.Add(1) {
+		case 1:
+			c.String(http.StatusOK, "ok")
+		case 2:
+			c.String(http.StatusInternalServerError, "error")
+		case 3:
+			panic("handler failed")
+		default:
+			c.String(http.StatusOK, "new")
+		}
+	})
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec
+	}
+	// 过期后先返回旧的响应，后台更新
+	if b := get("/revalidate").Body.String(); b != "v1" {
+		t.Errorf("got %s | expected v1", b)
+	}
+	if b := get("/revalidate").Body.String(); b != "v1" {
+		t.Errorf("got %s | expected stale v1", b)
+	}
+	deadline := time.Now().Add(time.Second)
+	for b := ""; b != "v2"; b = get("/revalidate").Body.String() {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %s | expected refreshed v2", b)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// 生成出错或 panic 时返回旧的响应
+	for _, expected := range []string{"ok", "ok", "ok", "new"} {
+		rec := get("/error")
+		if rec.Code != http.StatusOK || rec.Body.String() != expected {
+			t.Errorf("got %d %s | expected 200 %s", rec.Code, rec.Body.String(), expected)
+		}
+	}
+}
+
+func TestCacheStaleIfErrorCoalesce(t *testing.T) {
+	r := NewRoute(nil)
+	var cache SyncMapCache
+	var arrived, calls atomic.Int32
+	release := make(chan struct{})
+	r.GET("/", func(c *HTTPContext) {
+		arrived.Add(1)
+		c.Next()
+	}, CacheMiddlewareWithConfig(CacheConfig{Cache: &cache}), func(c *HTTPContext) {
+		c.Writer.Header().Set("Cache-Control", "max-age=0, stale-if-error=60")
+		if calls.Add(1) == 1 {
+			c.String(http.StatusOK, "ok")
+			return
+		}
+		<-release
+		c.String(http.StatusInternalServerError, "error")
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	arrived.Store(0)
+	// 过期的缓存在 stale-if-error 期间，并发请求只生成一次，出错后均返回旧的响应
+	const n = 10
+	var wg sync.WaitGroup
+	bodies := make([]string, n)
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+			bodies[i] = rec.Body.String()
+		}()
+	}
+	for arrived.Load() < n {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if calls.Load() != 2 {
+		t.Errorf("got %d calls | expected 2", calls.Load())
+	}
+	for _, b := range bodies {
+		if b != "ok" {
+			t.Errorf("got %s | expected stale ok", b)
+		}
+	}
+}
+
+func TestCacheStaleIfErrorEmpty(t *testing.T) {
+	r := NewRoute(nil)
+	var cache SyncMapCache
+	var calls atomic.Int32
+	r.GET("/", func(c *HTTPContext) {
+		defer func() {
+			if v := recover(); v != nil {
+				t.Errorf("got panic %v | expected none", v)
+			}
+		}()
+		c.Next()
+	}, CacheMiddlewareWithConfig(CacheConfig{Cache: &cache, StaleIfError: time.Minute}), func(c *HTTPContext) {
+		if calls.Add(1) == 1 {
+			c.Writer.Header().Set("Cache-Control", "max-age=0")
+			c.String(http.StatusOK, "ok")
+		}
+		// 之后不写入，隐式的 200 空响应
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if calls.Load() != 2 || rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+		t.Errorf("got %d calls, %d %q | expected 2 calls, empty 200", calls.Load(), rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,322 @@
+package whttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Compressor 压缩写入端，Reset 后复用
+type Compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// CompressorFunc 以 level 创建写入 w 的压缩器，level 为 0 时使用缺省级别，为 CompressLevelZero 时使用级别 0
+type CompressorFunc func(w io.Writer, level int) (Compressor, error)
+
+// CompressLevelZero 表示压缩器的级别 0（如 gzip.NoCompression），以区别于表示缺省级别的 0
+const CompressLevelZero = math.MinInt32
+
+// compressLevel 将 CompressorFunc 的 level 转换为压缩器的级别，def 为其缺省级别
+func compressLevel(level, def int) int {
+	switch level {
+	case 0:
+		return def
+	case CompressLevelZero:
+		return 0
+	}
+	return level
+}
+
+// compressors 按 Content-Encoding 注册的压缩器，compressEncodings 为协商时的优先顺序
+var (
+	compressors = map[string]CompressorFunc{
+		"gzip": func(w io.Writer, level int) (Compressor, error) {
+			return gzip.NewWriterLevel(w, compressLevel(level, gzip.DefaultCompression))
+		},
+		// HTTP 的 deflate 为 zlib 格式
+		"deflate": func(w io.Writer, level int) (Compressor, error) {
+			return zlib.NewWriterLevel(w, compressLevel(level, zlib.DefaultCompression))
+		},
+	}
+	compressEncodings = []string{"gzip", "deflate"}
+)
+
+// RegisterCompressor 注册或替换某一 Content-Encoding 的压缩器，如 br、zstd，需在创建中间件前调用，
+// 新注册的编码优先于已有的编码
+func RegisterCompressor(encoding string, fn CompressorFunc) {
+	if len(encoding) == 0 || fn == nil {
+		panic("compressor encoding and function cannot be empty")
+	}
+	encoding = strings.ToLower(encoding)
+	if _, ok := compressors[encoding]; !ok {
+		compressEncodings = append([]string{encoding}, compressEncodings...)
+	}
+	compressors[encoding] = fn
+}
+
+// CompressMinSize CompressConfig.MinSize 为 0 时的缺省值
+var CompressMinSize = 1024
+
+// DefaultCompressExcludedTypes CompressConfig.ExcludedTypes 为 nil 时不压缩的内容类型，多为已压缩的格式
+var DefaultCompressExcludedTypes = []string{
+	"image/png", "image/jpeg", "image/gif", "image/webp", "image/avif",
+	"video/*", "audio/*", "font/woff", "font/woff2",
+	"application/zip", "application/gzip", "application/x-gzip", "application/zstd",
+	"application/x-7z-compressed", "application/x-rar-compressed", "application/pdf",
+}
+
+// CompressConfig 压缩配置
+type CompressConfig struct {
+	// 压缩级别，0 为各压缩器的缺省级别，CompressLevelZero 为级别 0
+	Level int
+	// 可用的编码，q 值相同时靠前的优先，空时使用全部已注册的编码
+	Encodings []string
+	// 响应体小于该字节数时不压缩，0 时使用 CompressMinSize，小于 0 时不限制
+	MinSize int
+	// 只压缩这些内容类型，支持 "text/*" 形式，空时不限制
+	Types []string
+	// 不压缩的内容类型，nil 时使用 DefaultCompressExcludedTypes
+	ExcludedTypes []string
+}
+
+// CompressMiddleware 按 Accept-Encoding 的 q 值选择编码压缩响应。
+// 响应体小于 MinSize、内容类型不符、已带有 Content-Encoding、Cache-Control 含 no-transform 及 206 响应不压缩，
+// 压缩器经 sync.Pool 复用
+func CompressMiddleware(cfg CompressConfig) func(*HTTPContext) {
+	// 复制，防止修改调用者与共享的切片
+	encodings := append([]string(nil), cfg.Encodings...)
+	if len(encodings) == 0 {
+		encodings = append(encodings, compressEncodings...)
+	}
+	pools := make(map[string]*sync.Pool, len(encodings))
+	for i, enc := range encodings {
+		enc = strings.ToLower(enc)
+		encodings[i] = enc
+		fn, ok := compressors[enc]
+		if !ok {
+			panic("compressMiddleware: unknown encoding " + enc)
+		}
+		if _, err := fn(io.Discard, cfg.Level); err != nil {
+			panic("compressMiddleware: " + err.Error())
+		}
+		pools[enc] = &sync.Pool{New: func() any {
+			cw, _ := fn(io.Discard, cfg.Level)
+			return cw
+		}}
+	}
+	o := &compressOptions{minSize: cfg.MinSize, types: cfg.Types, excluded: cfg.ExcludedTypes}
+	if o.minSize == 0 {
+		o.minSize = CompressMinSize
+	}
+	if o.excluded == nil {
+		o.excluded = DefaultCompressExcludedTypes
+	}
+	return func(c *HTTPContext) {
+		enc := acceptEncoding(c.Request.Header.Values("Accept-Encoding"), encodings)
+		head := c.Request.Method == http.MethodHead
+		pool := c.route.pool
+		c.Transform(func(h http.Header, status int, w io.Writer) io.WriteCloser {
+			if len(h.Get("Content-Encoding")) > 0 {
+				return nil
+			}
+			// 304 等无响应体的响应同样带有 Vary，与完整的响应一致
+			addVary(h, "Accept-Encoding")
+			if len(enc) == 0 || !bodyAllowed(status) || status == http.StatusPartialContent ||
+				strings.Contains(strings.ToLower(h.Get("Cache-Control")), "no-transform") {
+				return nil
+			}
+			if n, err := strconv.Atoi(h.Get("Content-Length")); err == nil && n < o.minSize && !head {
+				return nil
+			}
+			return &compressWriter{o: o, h: h, w: w, encoding: enc, pool: pools[enc], head: head,
+				buf: pool.AllocBuffer(), free: pool.FreeBuffer}
+		})
+		c.Next()
+	}
+}
+
+// compressOptions CompressMiddleware 的内容判定
+type compressOptions struct {
+	minSize  int
+	types    []string
+	excluded []string
+}
+
+// allow 是否压缩该内容类型
+func (o *compressOptions) allow(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if len(o.types) > 0 && !matchMediaType(o.types, mediaType) {
+		return false
+	}
+	return !matchMediaType(o.excluded, mediaType)
+}
+
+// matchMediaType mediaType 是否匹配 list 中的一项，支持 "text/*" 形式
+func matchMediaType(list []string, mediaType string) bool {
+	typ, _, _ := strings.Cut(mediaType, "/")
+	for _, v := range list {
+		if strings.EqualFold(v, mediaType) {
+			return true
+		}
+		if t, ok := strings.CutSuffix(v, "/*"); ok && strings.EqualFold(t, typ) {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptEncoding 从 offered 中选出 Accept-Encoding q 值最高的编码，q 值相同时按 offered 的顺序，
+// "*" 匹配未列出的编码，均不可接受时返回空串
+func acceptEncoding(values []string, offered []string) string {
+	q := make(map[string]float64)
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			name, params, _ := strings.Cut(part, ";")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if len(name) == 0 {
+				continue
+			}
+			q[name] = 1
+			for _, p := range strings.Split(params, ";") {
+				k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+				if strings.EqualFold(k, "q") {
+					f, err := strconv.ParseFloat(v, 64)
+					if err != nil || f < 0 || f > 1 {
+						f = 0
+					}
+					q[name] = f
+				}
+			}
+		}
+	}
+	best, bestQ := "", 0.0
+	for _, enc := range offered {
+		v, ok := q[enc]
+		if !ok {
+			v = q["*"]
+		}
+		if v > bestQ {
+			best, bestQ = enc, v
+		}
+	}
+	return best
+}
+
+// addVary 向 Vary 添加尚未列出的字段
+func addVary(h http.Header, field string) {
+	for _, v := range h.Values("Vary") {
+		for _, f := range strings.Split(v, ",") {
+			if f = strings.TrimSpace(f); f == "*" || strings.EqualFold(f, field) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", field)
+}
+
+// compressWriter 缓存不足 minSize 的数据，达到 minSize、刷新或关闭时决定是否压缩
+type compressWriter struct {
+	o        *compressOptions
+	h        http.Header
+	w        io.Writer
+	encoding string
+	pool     *sync.Pool
+	// HEAD 请求没有响应体时按 Content-Length 判定
+	head bool
+	buf  *bytes.Buffer
+	free func(*bytes.Buffer)
+	cw   Compressor
+	// 决定后的写入端，cw 或 w
+	out io.Writer
+}
+
+// start 决定是否压缩并写出缓存的数据，flush 时不考虑 minSize
+func (w *compressWriter) start(flush bool) error {
+	n := w.buf.Len()
+	if w.head && n == 0 {
+		if cl, err := strconv.Atoi(w.h.Get("Content-Length")); err == nil {
+			n = cl
+		}
+	}
+	contentType, sniff := w.h.Get("Content-Type"), false
+	if _, ok := w.h["Content-Type"]; !ok && w.buf.Len() > 0 {
+		// 压缩后底层无法再识别内容类型
+		contentType, sniff = http.DetectContentType(w.buf.Bytes()), true
+	}
+	w.out = w.w
+	if (flush || n >= w.o.minSize) && w.o.allow(contentType) {
+		if sniff {
+			w.h.Set("Content-Type", contentType)
+		}
+		w.h.Set("Content-Encoding", w.encoding)
+		w.h.Del("Content-Length")
+		// 压缩后的表示不再与强校验的 ETag 字节一致
+		if etag := w.h.Get("ETag"); strings.HasPrefix(etag, `"`) {
+			w.h.Set("ETag", "W/"+etag)
+		}
+		w.cw = w.pool.Get().(Compressor)
+		w.cw.Reset(w.w)
+		w.out = w.cw
+	}
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	_, err := w.out.Write(w.buf.Bytes())
+	w.buf.Reset()
+	return err
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if w.out != nil {
+		return w.out.Write(p)
+	}
+	w.buf.Write(p)
+	if w.buf.Len() >= w.o.minSize {
+		if err := w.start(false); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (w *compressWriter) Flush() error {
+	if w.out == nil {
+		if err := w.start(true); err != nil {
+			return err
+		}
+	}
+	if w.cw != nil {
+		return w.cw.Flush()
+	}
+	return nil
+}
+
+func (w *compressWriter) Close() error {
+	var err error
+	if w.out == nil {
+		err = w.start(false)
+	}
+	if w.buf != nil {
+		w.free(w.buf)
+		w.buf = nil
+	}
+	if w.cw != nil {
+		if e := w.cw.Close(); err == nil {
+			err = e
+		}
+		w.cw.Reset(io.Discard)
+		w.pool.Put(w.cw)
+		w.cw = nil
+	}
+	return err
+}
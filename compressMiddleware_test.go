@@ -0,0 +1,198 @@
+package whttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAcceptEncoding(t *testing.T) {
+	offered := []string{"gzip", "deflate"}
+	tests := []struct {
+		header, expected string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"gzip;q=0", ""},
+		{"GZIP, deflate", "gzip"},
+		{"gzip;q=0.5, deflate", "deflate"},
+		{"br", ""},
+		{"*;q=0.1", "gzip"},
+		{"*, gzip;q=0", "deflate"},
+		{"identity", ""},
+	}
+	for _, tt := range tests {
+		if got := acceptEncoding([]string{tt.header}, offered); got != tt.expected {
+			t.Errorf("%q: got %q | expected %q", tt.header, got, tt.expected)
+		}
+	}
+}
+
+// reverseWriter 测试用的压缩器，Close 时逆序写出
+type reverseWriter struct {
+	w   io.Writer
+	buf []byte
+}
+
+func (r *reverseWriter) Write(p []byte) (int, error) {
+	r.buf = append(r.buf, p...)
+	return len(p), nil
+}
+
+func (r *reverseWriter) Flush() error {
+	return nil
+}
+
+func (r *reverseWriter) Close() error {
+	for i, j := 0, len(r.buf)-1; i < j; i, j = i+1, j-1 {
+		r.buf[i], r.buf[j] = r.buf[j], r.buf[i]
+	}
+	_, err := r.w.Write(r.buf)
+	return err
+}
+
+func (r *reverseWriter) Reset(w io.Writer) {
+	r.w = w
+	r.buf = r.buf[:0]
+}
+
+func TestCompressMiddleware(t *testing.T) {
+	RegisterCompressor("x-reverse", func(w io.Writer, level int) (Compressor, error) {
+		return &reverseWriter{w: w}, nil
+	})
+	text := strings.Repeat("hello world ", 200)
+	r := NewRoute(nil)
+	r.Use(CompressMiddleware(CompressConfig{}))
+	r.GET("/text", func(c *HTTPContext) {
+		c.Writer.Header().Set("ETag", `"v1"`)
+		c.String(http.StatusOK, text)
+	})
+	r.GET("/small", func(c *HTTPContext) {
+		c.String(http.StatusOK, "hello")
+	})
+	r.GET("/png", func(c *HTTPContext) {
+		c.Blob(http.StatusOK, "image/png", []byte(text))
+	})
+	r.GET("/encoded", func(c *HTTPContext) {
+		c.Writer.Header().Set("Content-Encoding", "br")
+		c.String(http.StatusOK, text)
+	})
+	r.GET("/304", func(c *HTTPContext) {
+		c.Writer.WriteHeader(http.StatusNotModified)
+	})
+	tests := []struct {
+		method, path, accept string
+		encoding             string
+	}{
+		{"GET", "/text", "gzip", "gzip"},
+		{"GET", "/text", "gzip;q=0.5, deflate", "deflate"},
+		{"GET", "/text", "gzip;q=0", ""},
+		{"GET", "/text", "x-reverse, gzip", "x-reverse"},
+		{"HEAD", "/text", "gzip", "gzip"},
+		{"GET", "/small", "gzip", ""},
+		{"GET", "/png", "gzip", ""},
+		{"GET", "/encoded", "gzip", "br"},
+		{"GET", "/304", "gzip", ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Header.Set("Accept-Encoding", tt.accept)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		name := tt.method + " " + tt.path + " " + tt.accept
+		if got := rec.Header().Get("Content-Encoding"); got != tt.encoding {
+			t.Errorf("%s: got Content-Encoding %q | expected %q", name, got, tt.encoding)
+			continue
+		}
+		if tt.path != "/encoded" && rec.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("%s: got Vary %q | expected Accept-Encoding", name, rec.Header().Get("Vary"))
+		}
+		if tt.method == "HEAD" {
+			if rec.Body.Len() > 0 || len(rec.Header().Get("Content-Length")) > 0 {
+				t.Errorf("%s: got %d bytes, Content-Length %q | expected empty", name, rec.Body.Len(), rec.Header().Get("Content-Length"))
+			}
+			continue
+		}
+		var body io.Reader = rec.Body
+		switch tt.encoding {
+		case "gzip":
+			body, _ = gzip.NewReader(body)
+		case "deflate":
+			body, _ = zlib.NewReader(body)
+		}
+		data, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if tt.encoding == "x-reverse" {
+			for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {
+				data[i], data[j] = data[j], data[i]
+			}
+		}
+		if tt.path == "/text" && (string(data) != text || len(tt.encoding) > 0 && rec.Header().Get("ETag") != `W/"v1"`) {
+			t.Errorf("%s: got %d bytes, ETag %s | expected %d bytes", name, len(data), rec.Header().Get("ETag"), len(text))
+		}
+	}
+}
+
+func TestCompressFlush(t *testing.T) {
+	r := NewRoute(nil)
+	r.GET("/", CompressMiddleware(CompressConfig{}), func(c *HTTPContext) {
+		c.Writer.Header().Set("Content-Type", "text/event-stream")
+		c.Writer.Write([]byte("data: 1\n\n"))
+		c.Writer.(http.Flusher).Flush()
+	})
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	// 刷新时不考虑大小下限，已写出的数据可以解压
+	if !rec.Flushed || rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("got %v %q | expected flushed gzip", rec.Flushed, rec.Header().Get("Content-Encoding"))
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(rec.Body.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := io.ReadAll(zr); string(data) != "data: 1\n\n" {
+		t.Errorf("got %q | expected data: 1", data)
+	}
+}
+
+func TestCompressLevel(t *testing.T) {
+	text := strings.Repeat("hello world ", 200)
+	size := func(mw func(*HTTPContext)) int {
+		r := NewRoute(nil)
+		r.GET("/", mw, func(c *HTTPContext) {
+			c.String(http.StatusOK, text)
+		})
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		n := rec.Body.Len()
+		zr, err := gzip.NewReader(rec.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data, _ := io.ReadAll(zr); string(data) != text {
+			t.Fatalf("got %d bytes | expected %d bytes", len(data), len(text))
+		}
+		return n
+	}
+	// 级别 0 只存储不压缩，0 值的 CompressConfig.Level 仍为缺省级别
+	if n := size(GZIPMiddleware(gzip.NoCompression)); n <= len(text) {
+		t.Errorf("got %d bytes | expected stored more than %d bytes", n, len(text))
+	}
+	if n := size(CompressMiddleware(CompressConfig{Level: CompressLevelZero, MinSize: -1})); n <= len(text) {
+		t.Errorf("got %d bytes | expected stored more than %d bytes", n, len(text))
+	}
+	if n := size(CompressMiddleware(CompressConfig{})); n >= len(text)/10 {
+		t.Errorf("got %d bytes | expected compressed", n)
+	}
+}
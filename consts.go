@@ -27,6 +27,7 @@ const (
 	MIMEApplicationDownload     = "application/x-msdownload"
 	MIMEApplicationJSON         = "application/json"
 	MIMEApplicationJSONUTF8     = "application/json; charset=utf-8"
+	MIMEApplicationProblemJSON  = "application/problem+json"
 	MIMEApplicationXML          = "application/xml"
 	MIMEApplicationXMLUTF8      = "application/xml; charset=utf-8"
 	MIMEApplicationZip          = "application/zip"
@@ -38,6 +39,8 @@ const (
 	MIMEApplicationOpenXMLExcel = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
 	MIMEApplicationOpenXMLPPT   = "application/vnd.openxmlformats-officedocument.presentationml.presentation"
 	MIMEApplicationWASM         = "application/wasm"
+	MIMEApplicationYAML         = "application/yaml"
+	MIMEApplicationMsgPack      = "application/msgpack"
 	// MIME image
 	MIMEImageJPEG         = "image/jpeg"
 	MIMEImagePNG          = "image/png"
@@ -2,14 +2,17 @@ package whttp
 
 import (
 	"bytes"
-	"errors"
-	"fmt"
+	"context"
 	"io"
+	"maps"
+	"math"
 	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/duomi520/utils"
@@ -20,40 +23,56 @@ type H map[string]any
 
 // HTTPContext 上下文
 type HTTPContext struct {
-	index                int
-	status               int
-	chain                []func(*HTTPContext)
-	mu                   sync.RWMutex
-	keys                 utils.MetaDict[any]
-	Writer               http.ResponseWriter
-	Request              *http.Request
+	index int
+	chain []func(*HTTPContext)
+	mu    sync.RWMutex
+	keys  utils.MetaDict[any]
+	// SetAs 设置的值，以 typedKey 为键，与 keys 互不影响
+	values  map[any]any
+	Writer  http.ResponseWriter
+	Request *http.Request
+	// Deprecated: 需缓存完整的响应体，使用 Transform 注册流式变换
 	HookBeforWriteHeader []func(*bytes.Buffer) *bytes.Buffer
 	route                *WRoute
+	// 未经长度限制的原始请求体
+	body io.ReadCloser
+	// AbortWithError 记录的错误
+	errors []error
+	// 包装 http.ResponseWriter，记录状态码与字节数
+	rw responseWriter
+	// Copy 得到的只读副本
+	readonly bool
+	// 调试模式下请求结束后置为 true，不再放回池中
+	released atomic.Bool
 }
 
 func (c *HTTPContext) reset() {
 	c.index = 0
-	c.status = 0
-	if len(c.chain) > 0 {
-		c.chain = c.chain[:0]
-	}
+	// chain 与路由共享，只释放引用
+	c.chain = nil
 	if c.keys.Len() > 0 {
 		c.keys.Key = c.keys.Key[:0]
 		c.keys.Value = c.keys.Value[:0]
 	}
+	clear(c.values)
 	c.Writer = nil
+	c.rw.reset(nil, nil)
 	c.Request = nil
 	if len(c.HookBeforWriteHeader) > 0 {
 		c.HookBeforWriteHeader = c.HookBeforWriteHeader[:0]
 	}
 	c.route = nil
+	c.body = nil
+	if len(c.errors) > 0 {
+		clear(c.errors)
+		c.errors = c.errors[:0]
+	}
 }
 
 var HTTPContextPool = sync.Pool{
 	New: func() any {
 		return &HTTPContext{
-			index:  0,
-			status: 0,
+			index: 0,
 		}
 	},
 }
@@ -81,6 +100,10 @@ func (c *HTTPContext) Error(msg string, args ...any) {
 
 // Set 上下文key-value值
 func (c *HTTPContext) Set(k string, v any) {
+	c.alive()
+	if c.readonly {
+		panic(errReadonly)
+	}
 	c.mu.Lock()
 	c.keys = c.keys.Set(k, v)
 	c.mu.Unlock()
@@ -88,6 +111,7 @@ func (c *HTTPContext) Set(k string, v any) {
 
 // Get 上下文key-value值
 func (c *HTTPContext) Get(k string) (v any, b bool) {
+	c.alive()
 	c.mu.RLock()
 	v, b = c.keys.Get(k)
 	c.mu.RUnlock()
@@ -96,52 +120,131 @@ func (c *HTTPContext) Get(k string) (v any, b bool) {
 
 // Del 上下文key-value值
 func (c *HTTPContext) Del(k string) {
+	c.alive()
+	if c.readonly {
+		panic(errReadonly)
+	}
 	c.mu.Lock()
 	c.keys = c.keys.Del(k)
 	c.mu.Unlock()
 }
 
-// BindJSON 绑定JSON数据
-func (c *HTTPContext) BindJSON(v any) error {
-	buf, err := io.ReadAll(c.Request.Body)
-	defer c.Request.Body.Close()
-	if err != nil {
-		return fmt.Errorf("reread body failed: %w", err)
+// Key 类型化的上下文键，由 SetAs、GetAs 存取，名称相同而类型不同的键互不影响，也不与 Set 的键冲突
+//
+//	var UserKey whttp.Key[*User] = "user"
+type Key[T any] string
+
+// typedKey 值表中 Key[T] 对应的键
+type typedKey[T any] struct {
+	name string
+}
+
+func (k Key[T]) contextKey() any {
+	return typedKey[T]{name: string(k)}
+}
+
+// SetAs 以类型化的键设置上下文值
+func SetAs[T any](c *HTTPContext, key Key[T], v T) {
+	c.alive()
+	if c.readonly {
+		panic(errReadonly)
 	}
-	err = DefaultUnmarshal(buf, v)
-	if err != nil {
-		return fmt.Errorf("unmarshal %v failed: %w", v, err)
+	c.mu.Lock()
+	if c.values == nil {
+		c.values = make(map[any]any)
 	}
-	return nil
+	c.values[key.contextKey()] = v
+	c.mu.Unlock()
 }
 
-func (c *HTTPContext) write(status int, b []byte) (n int, err error) {
-	c.status = status
-	if len(c.HookBeforWriteHeader) > 0 {
-		buf := c.route.pool.AllocBuffer()
-		defer c.route.pool.FreeBuffer(buf)
-		n, err = buf.Write(b)
-		if err != nil {
-			return
-		}
-		for i := len(c.HookBeforWriteHeader) - 1; i > -1; i-- {
-			buf = c.HookBeforWriteHeader[i](buf)
-			if buf == nil {
-				return 0, errors.New("hookBeforWriteHeader return nil")
-			}
+// GetAs 以类型化的键取得上下文值，不存在时返回零值与 false
+func GetAs[T any](c *HTTPContext, key Key[T]) (v T, ok bool) {
+	a, ok := c.value(key.contextKey())
+	if ok {
+		v = a.(T)
+	}
+	return
+}
+
+// value 取得 SetAs 设置的值
+func (c *HTTPContext) value(k any) (v any, ok bool) {
+	c.alive()
+	c.mu.RLock()
+	v, ok = c.values[k]
+	c.mu.RUnlock()
+	return
+}
+
+var _ context.Context = (*HTTPContext)(nil)
+
+// canceledContext 请求已结束的上下文
+var canceledContext = func() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}()
+
+// requestContext 请求的 context，上下文已回收时视为已取消
+func (c *HTTPContext) requestContext() context.Context {
+	if c.Request == nil {
+		return canceledContext
+	}
+	return c.Request.Context()
+}
+
+// Deadline 实现 context.Context，转交请求的 context
+func (c *HTTPContext) Deadline() (time.Time, bool) {
+	return c.requestContext().Deadline()
+}
+
+// Done 实现 context.Context，转交请求的 context
+func (c *HTTPContext) Done() <-chan struct{} {
+	return c.requestContext().Done()
+}
+
+// Err 实现 context.Context，转交请求的 context
+func (c *HTTPContext) Err() error {
+	return c.requestContext().Err()
+}
+
+// Value 实现 context.Context，Key 类型的键取 SetAs 设置的值，string 类型的键取 Set 设置的值，
+// 未找到时转交请求的 context。
+// 不要以 c 作为 c.Request 的 context 的父级，否则 Value 将循环调用
+func (c *HTTPContext) Value(key any) any {
+	c.alive()
+	switch k := key.(type) {
+	case interface{ contextKey() any }:
+		if v, ok := c.value(k.contextKey()); ok {
+			return v
 		}
-		c.Writer.WriteHeader(c.status)
-		var size int64
-		size, err = io.Copy(c.Writer, buf)
-		if err != nil {
-			c.Warn("write", "error", err.Error())
-			err = nil
+	case string:
+		if v, ok := c.Get(k); ok {
+			return v
 		}
-		n = int(size)
-	} else {
-		c.Writer.WriteHeader(c.status)
-		n, err = c.Writer.Write(b)
 	}
+	return c.requestContext().Value(key)
+}
+
+// BindJSON 绑定JSON数据
+func (c *HTTPContext) BindJSON(v any) error {
+	c.alive()
+	return validate(v, c.bindJSON(v))
+}
+
+// BindJSONStrict 以严格模式绑定JSON数据，拒绝未知字段及多余的数据
+func (c *HTTPContext) BindJSONStrict(v any) error {
+	c.alive()
+	return validate(v, decodeJSON(c.Request.Body, v, true))
+}
+
+func (c *HTTPContext) bindJSON(v any) error {
+	return decodeJSON(c.Request.Body, v, c.route != nil && c.route.strictJSON)
+}
+
+func (c *HTTPContext) write(status int, b []byte) (n int, err error) {
+	c.alive()
+	c.Writer.WriteHeader(status)
+	n, err = c.Writer.Write(b)
 	c.route.HookIOWriteError(c, n, err)
 	return
 }
@@ -170,6 +273,7 @@ func (c *HTTPContext) Blob(status int, contentType string, data []byte) {
 
 // File 将静态文件返回给客户端
 func (c *HTTPContext) File(path string) {
+	c.alive()
 	// 清理路径防止目录遍历
 	path = filepath.Clean(path)
 	// 打开请求的文件
@@ -213,77 +317,140 @@ func (c *HTTPContext) File(path string) {
 	if len(ctype) > 0 {
 		c.Writer.Header().Set("Content-Type", ctype)
 	}
-	c.status = http.StatusOK
-	if len(c.HookBeforWriteHeader) > 0 {
-		buf := c.route.pool.AllocBuffer()
-		defer c.route.pool.FreeBuffer(buf)
-		n64, err := io.Copy(buf, f)
-		if err != nil {
-			c.route.HookIOWriteError(c, int(n64), err)
-			return
-		}
-		for i := len(c.HookBeforWriteHeader) - 1; i > -1; i-- {
-			buf = c.HookBeforWriteHeader[i](buf)
-			if buf == nil {
-				c.route.HookIOWriteError(c, 0, errors.New("file hookBeforWriteHeader return nil"))
-				return
-			}
-		}
-		c.Writer.WriteHeader(c.status)
-		n64, err = io.Copy(c.Writer, buf)
-		c.route.HookIOWriteError(c, int(n64), err)
-	} else {
-		c.Writer.WriteHeader(c.status)
-		n64, err := io.Copy(c.Writer, f)
-		c.route.HookIOWriteError(c, int(n64), err)
-	}
+	// 经 responseWriter.ReadFrom，无变换时使用 sendfile
+	c.Writer.WriteHeader(http.StatusOK)
+	n64, err := io.Copy(c.Writer, f)
+	c.route.HookIOWriteError(c, int(n64), err)
 }
 
-// Render 渲染模板
+// Render 渲染模板，渲染完成后再写出，出错时返回 500
 func (c *HTTPContext) Render(status int, name string, v any) {
-	if c.route.renderer != nil {
-		c.Writer.Header().Set("Content-Type", "text/html")
-		c.status = status
-		if len(c.HookBeforWriteHeader) > 0 {
-			buf := c.route.pool.AllocBuffer()
-			defer c.route.pool.FreeBuffer(buf)
-			err := c.route.renderer.ExecuteTemplate(buf, name, v)
-			if err != nil {
-				c.Error("render", "error", err.Error())
-				c.write(http.StatusInternalServerError, []byte("server error"))
-				return
-			}
-			for i := len(c.HookBeforWriteHeader) - 1; i > -1; i-- {
-				buf = c.HookBeforWriteHeader[i](buf)
-				if buf == nil {
-					c.route.HookIOWriteError(c, 0, errors.New("render hookBeforWriteHeader return nil"))
-					return
-				}
-			}
-			var n64 int64
-			c.Writer.WriteHeader(c.status)
-			n64, err = io.Copy(c.Writer, buf)
-			c.route.HookIOWriteError(c, int(n64), err)
-		} else {
-			c.Writer.WriteHeader(c.status)
-			err := c.route.renderer.ExecuteTemplate(c.Writer, name, v)
-			if err != nil {
-				c.Error("render", "error", err.Error())
-				c.write(http.StatusInternalServerError, []byte("server error"))
-			}
-		}
-	} else {
+	c.alive()
+	if c.route.renderer == nil {
 		c.Error("renderer not initialized", nil)
 		c.write(http.StatusInternalServerError, []byte("server error"))
+		return
 	}
+	buf := c.route.pool.AllocBuffer()
+	defer c.route.pool.FreeBuffer(buf)
+	if err := c.route.renderer.ExecuteTemplate(buf, name, v); err != nil {
+		c.Error("render", "error", err.Error())
+		c.write(http.StatusInternalServerError, []byte("server error"))
+		return
+	}
+	c.Writer.Header().Set("Content-Type", "text/html")
+	c.write(status, buf.Bytes())
 }
 
-// Next 下一个
+// abortIndex 中止后 index 的值，大于任何处理链的长度
+const abortIndex = math.MaxInt / 2
+
+// Next 下一个，返回前关闭后续处理函数注册的 Transformer，调用者可读取完整的 BytesWritten
 func (c *HTTPContext) Next() {
+	c.alive()
+	index := c.index
 	c.index++
 	if c.index >= 0 && c.index < len(c.chain) {
 		c.chain[c.index](c)
 	}
+	if len(c.rw.stages) > 0 {
+		if err := c.rw.closeAfter(index); err != nil {
+			c.route.HookIOWriteError(c, c.rw.size, err)
+		}
+	}
+}
+
+// Abort 中止处理链，之后的 Next 不再执行后续处理函数，不影响当前函数的执行
+func (c *HTTPContext) Abort() {
+	c.alive()
+	c.index = abortIndex
+}
+
+// IsAborted 处理链是否已中止，外层中间件在 Next 返回后据此判断
+func (c *HTTPContext) IsAborted() bool {
+	c.alive()
+	return c.index >= abortIndex
+}
+
+// Status 已写入的响应状态码，未写入时为 0，包括直接写入 c.Writer 的响应
+func (c *HTTPContext) Status() int {
+	c.alive()
+	return c.rw.status
+}
+
+// BytesWritten 已写入的响应体字节数，包括直接写入 c.Writer 的响应
+func (c *HTTPContext) BytesWritten() int {
+	c.alive()
+	return c.rw.size
+}
+
+// Written 是否已写入响应头
+func (c *HTTPContext) Written() bool {
+	c.alive()
+	return c.rw.wroteHeader
+}
+
+// HandlerName 处理链中最后一个函数（路由处理函数）的名称
+func (c *HTTPContext) HandlerName() string {
+	c.alive()
+	if len(c.chain) == 0 {
+		return ""
+	}
+	return funcName(c.chain[len(c.chain)-1])
+}
+
+const (
+	errReleased = "whttp: HTTPContext used after the request completed, use c.Copy() in goroutines"
+	errReadonly = "whttp: HTTPContext copy is read-only"
+)
+
+// alive 调试模式下检测请求结束后对上下文的使用
+func (c *HTTPContext) alive() {
+	if c.released.Load() {
+		panic(errReleased)
+	}
+}
+
+// Copy 返回脱离请求生命周期的只读副本，供处理函数返回后仍在运行的 goroutine 使用。
+// 副本保留请求头、路径参数、上下文键值与响应状态的快照，请求体为空，
+// 请求的 context 不随请求结束而取消；Set、Del、Transform 及写入响应时 panic
+func (c *HTTPContext) Copy() *HTTPContext {
+	c.alive()
+	cp := &HTTPContext{
+		index:    abortIndex,
+		route:    c.route,
+		readonly: true,
+		Writer:   readonlyWriter{},
+	}
+	if c.Request != nil {
+		cp.Request = c.Request.Clone(context.WithoutCancel(c.Request.Context()))
+		cp.Request.Body = http.NoBody
+	}
+	c.mu.RLock()
+	cp.keys.Key = slices.Clone(c.keys.Key)
+	cp.keys.Value = slices.Clone(c.keys.Value)
+	cp.values = maps.Clone(c.values)
+	c.mu.RUnlock()
+	cp.errors = slices.Clone(c.errors)
+	cp.rw.status = c.rw.status
+	cp.rw.size = c.rw.size
+	cp.rw.wroteHeader = c.rw.wroteHeader
+	return cp
+}
+
+// readonlyWriter 只读副本的 Writer，写入时 panic
+type readonlyWriter struct{}
+
+func (readonlyWriter) Header() http.Header {
+	return http.Header{}
+}
+
+func (readonlyWriter) Write([]byte) (int, error) {
+	panic(errReadonly)
+}
+
+func (readonlyWriter) WriteHeader(int) {
+	panic(errReadonly)
 }
 
 // https://www.cnblogs.com/f-ck-need-u/p/10035801.html
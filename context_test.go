@@ -0,0 +1,155 @@
+package whttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// mustPanic 执行 fn 并返回 panic 的值
+func mustPanic(fn func()) (v any) {
+	defer func() {
+		v = recover()
+	}()
+	fn()
+	return nil
+}
+
+func TestCopy(t *testing.T) {
+	r := NewRoute(nil)
+	start := make(chan struct{})
+	done := make(chan string)
+	r.GET("/user/{name}", func(c *HTTPContext) {
+		c.Set("id", c.Param("name"))
+		c.String(http.StatusOK, "OK")
+		cp := c.Copy()
+		go func() {
+			// 处理函数返回后原上下文已被其他请求复用
+			<-start
+			v, _ := cp.Get("id")
+			done <- fmt.Sprintf("%s %s %d %v", cp.Param("name"), cp.Request.Header.Get("X-Trace"), cp.Status(), v)
+		}()
+	})
+	for _, name := range []string{"linda", "tom"} {
+		req := httptest.NewRequest("GET", "/user/"+name, nil)
+		req.Header.Set("X-Trace", name)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	close(start)
+	got := map[string]bool{<-done: true, <-done: true}
+	for _, expected := range []string{"linda linda 200 linda", "tom tom 200 tom"} {
+		if !got[expected] {
+			t.Errorf("got %v | expected %s", got, expected)
+		}
+	}
+}
+
+func TestCopyReadonly(t *testing.T) {
+	r := NewRoute(nil)
+	var cp *HTTPContext
+	r.GET("/", func(c *HTTPContext) {
+		cp = c.Copy()
+		c.String(http.StatusOK, "OK")
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if err := cp.Request.Context().Err(); err != nil {
+		t.Errorf("got %v | expected nil", err)
+	}
+	tests := []struct {
+		name string
+		fn   func()
+	}{
+		{"Set", func() { cp.Set("k", 1) }},
+		{"Del", func() { cp.Del("k") }},
+		{"SetAs", func() { SetAs(cp, Key[int]("k"), 1) }},
+		{"String", func() { cp.String(http.StatusOK, "x") }},
+		{"Transform", func() { cp.Transform(func(h http.Header, status int, w io.Writer) io.WriteCloser { return nil }) }},
+	}
+	for _, tt := range tests {
+		if v := mustPanic(tt.fn); v != errReadonly {
+			t.Errorf("%s: got %v | expected %s", tt.name, v, errReadonly)
+		}
+	}
+}
+
+func TestReleasedContext(t *testing.T) {
+	r := NewRoute(nil)
+	r.SetDebugMode(true)
+	var leaked *HTTPContext
+	r.GET("/", func(c *HTTPContext) {
+		leaked = c
+		c.String(http.StatusOK, "OK")
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	tests := []struct {
+		name string
+		fn   func()
+	}{
+		{"Set", func() { leaked.Set("k", 1) }},
+		{"Get", func() { leaked.Get("k") }},
+		{"String", func() { leaked.String(http.StatusOK, "x") }},
+		{"Param", func() { leaked.Param("k") }},
+		{"Copy", func() { leaked.Copy() }},
+	}
+	for _, tt := range tests {
+		if v := mustPanic(tt.fn); v != errReleased {
+			t.Errorf("%s: got %v | expected %s", tt.name, v, errReleased)
+		}
+	}
+}
+
+type ctxKey struct{}
+
+func TestContext(t *testing.T) {
+	var userKey Key[string] = "user"
+	var idKey Key[int] = "id"
+	r := NewRoute(nil)
+	r.GET("/", func(c *HTTPContext) {
+		SetAs(c, userKey, "linda")
+		// 类型化的键与 Set 的键、名称相同而类型不同的键互不影响
+		c.Set("user", "not typed")
+		c.Set("id", "not int")
+		SetAs(c, Key[int]("user"), 7)
+		var ctx context.Context = c
+		if v, ok := GetAs(c, userKey); !ok || v != "linda" {
+			t.Errorf("got %v %v | expected linda true", v, ok)
+		}
+		if v, ok := GetAs(c, idKey); ok || v != 0 {
+			t.Errorf("got %v %v | expected 0 false", v, ok)
+		}
+		if v, _ := c.Get("user"); v != "not typed" {
+			t.Errorf("got %v | expected not typed", v)
+		}
+		if ctx.Value(userKey) != "linda" || ctx.Value(Key[int]("user")) != 7 || ctx.Value("user") != "not typed" || ctx.Value(ctxKey{}) != "request" {
+			t.Errorf("got %v %v %v %v | expected linda, 7, not typed, request", ctx.Value(userKey), ctx.Value(Key[int]("user")), ctx.Value("user"), ctx.Value(ctxKey{}))
+		}
+		if ctx.Value("missing") != nil {
+			t.Errorf("got %v | expected nil", ctx.Value("missing"))
+		}
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("got no deadline | expected request deadline")
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+			t.Error("got not done | expected done")
+		}
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			t.Errorf("got %v | expected %v", ctx.Err(), context.DeadlineExceeded)
+		}
+		c.String(http.StatusOK, "OK")
+	})
+	ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), ctxKey{}, "request"), 10*time.Millisecond)
+	defer cancel()
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequestWithContext(ctx, "GET", "/", nil))
+	// 已回收的上下文视为已取消
+	var c HTTPContext
+	if c.Err() != context.Canceled {
+		t.Errorf("got %v | expected %v", c.Err(), context.Canceled)
+	}
+}
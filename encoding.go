@@ -0,0 +1,490 @@
+package whttp
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// 内置的 YAML、CSV、MessagePack 编码器，仅依赖标准库，需要完整特性时可用 RegisterEncoder 替换
+
+var textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+
+// encodeField 结构体中参与编码的字段
+type encodeField struct {
+	name      string
+	index     []int
+	omitempty bool
+}
+
+// encodeFields 按 tags 的顺序取字段名，均无标签时使用字段名，匿名结构体字段展开；
+// 与 encoding/json 相同，未导出的匿名结构体也展开其导出的字段
+func encodeFields(t reflect.Type, tags ...string) []encodeField {
+	var fields []encodeField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if !f.IsExported() {
+			if f.Anonymous && ft.Kind() == reflect.Struct {
+				for _, sub := range encodeFields(ft, tags...) {
+					sub.index = append([]int{i}, sub.index...)
+					fields = append(fields, sub)
+				}
+			}
+			continue
+		}
+		name, omitempty, tagged := f.Name, false, false
+		for _, tag := range tags {
+			if v, ok := f.Tag.Lookup(tag); ok {
+				n, opts, _ := strings.Cut(v, ",")
+				if n == "-" && len(opts) == 0 {
+					name = ""
+				} else if len(n) > 0 {
+					name = n
+				}
+				omitempty, tagged = strings.Contains(opts, "omitempty"), true
+				break
+			}
+		}
+		if len(name) == 0 {
+			continue
+		}
+		if f.Anonymous && !tagged && ft.Kind() == reflect.Struct && !ft.Implements(textMarshalerType) {
+			for _, sub := range encodeFields(ft, tags...) {
+				sub.index = append([]int{i}, sub.index...)
+				fields = append(fields, sub)
+			}
+			continue
+		}
+		fields = append(fields, encodeField{name: name, index: []int{i}, omitempty: omitempty})
+	}
+	return fields
+}
+
+// fieldByIndex 经过空指针时返回无效值
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 {
+			if v.Kind() == reflect.Pointer {
+				if v.IsNil() {
+					return reflect.Value{}
+				}
+				v = v.Elem()
+			}
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// indirect 解开指针与接口，nil 时返回无效值
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// marshalText 实现 encoding.TextMarshaler 的值转为文本
+func marshalText(v reflect.Value) (string, bool, error) {
+	if !v.Type().Implements(textMarshalerType) {
+		if !v.CanAddr() || !reflect.PointerTo(v.Type()).Implements(textMarshalerType) {
+			return "", false, nil
+		}
+		v = v.Addr()
+	}
+	b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+	return string(b), true, err
+}
+
+// sortedKeys map 的键按文本排序，保证输出稳定
+func sortedKeys(v reflect.Value) []reflect.Value {
+	keys := v.MapKeys()
+	slices.SortFunc(keys, func(a, b reflect.Value) int {
+		return strings.Compare(fmt.Sprint(a.Interface()), fmt.Sprint(b.Interface()))
+	})
+	return keys
+}
+
+// encodeYAML 将数据编码为块格式的 YAML
+func encodeYAML(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeYAML(&buf, reflect.ValueOf(v), 0); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeYAML 以 indent 缩进写入一个节点，以换行结束
+func writeYAML(buf *bytes.Buffer, v reflect.Value, indent int) error {
+	v = indirect(v)
+	pad := strings.Repeat(" ", indent)
+	if !v.IsValid() {
+		buf.WriteString(pad + "null\n")
+		return nil
+	}
+	if s, ok, err := marshalText(v); ok || err != nil {
+		buf.WriteString(pad + yamlString(s) + "\n")
+		return err
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		fields := encodeFields(v.Type(), "yaml", "json")
+		n := 0
+		for _, f := range fields {
+			fv := fieldByIndex(v, f.index)
+			if !fv.IsValid() || f.omitempty && fv.IsZero() {
+				continue
+			}
+			if err := writeYAMLEntry(buf, pad+yamlString(f.name)+":", fv, indent); err != nil {
+				return err
+			}
+			n++
+		}
+		if n == 0 {
+			buf.WriteString(pad + "{}\n")
+		}
+	case reflect.Map:
+		if v.Len() == 0 {
+			buf.WriteString(pad + "{}\n")
+			return nil
+		}
+		for _, k := range sortedKeys(v) {
+			if err := writeYAMLEntry(buf, pad+yamlString(fmt.Sprint(k.Interface()))+":", v.MapIndex(k), indent); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Len() == 0 {
+			buf.WriteString(pad + "[]\n")
+			return nil
+		}
+		for i := 0; i < v.Len(); i++ {
+			// 子节点按 indent+2 写入，再将其首行缩进替换为 "- "
+			start := buf.Len()
+			if err := writeYAML(buf, v.Index(i), indent+2); err != nil {
+				return err
+			}
+			copy(buf.Bytes()[start+indent:], "- ")
+		}
+	default:
+		s, err := yamlScalar(v)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(pad + s + "\n")
+	}
+	return nil
+}
+
+// writeYAMLEntry 写入映射中的一项，标量与空容器同行，其余换行缩进
+func writeYAMLEntry(buf *bytes.Buffer, key string, v reflect.Value, indent int) error {
+	v = indirect(v)
+	buf.WriteString(key)
+	if v.IsValid() {
+		if _, ok, _ := marshalText(v); !ok {
+			switch v.Kind() {
+			case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+				if v.Kind() == reflect.Struct || v.Len() > 0 {
+					buf.WriteByte('\n')
+					return writeYAML(buf, v, indent+2)
+				}
+			}
+		}
+	}
+	buf.WriteByte(' ')
+	return writeYAML(buf, v, 0)
+}
+
+// yamlScalar 基本类型的字面量
+func yamlScalar(v reflect.Value) (string, error) {
+	switch v.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		switch {
+		case math.IsNaN(f):
+			return ".nan", nil
+		case math.IsInf(f, 1):
+			return ".inf", nil
+		case math.IsInf(f, -1):
+			return "-.inf", nil
+		}
+		return strconv.FormatFloat(f, 'g', -1, v.Type().Bits()), nil
+	case reflect.String:
+		return yamlString(v.String()), nil
+	}
+	return "", fmt.Errorf("yaml: unsupported type %s", v.Type())
+}
+
+// yamlString 除安全的纯量外一律加双引号：以字母或 _ 开头，只含字母、数字、空格与 _-./，
+// 且不是 null、true 等关键字。日期、时间、0x1F 等可能被解析为其他类型的字符串均加引号
+func yamlString(s string) string {
+	if len(s) == 0 || s != strings.TrimSpace(s) {
+		return strconv.Quote(s)
+	}
+	switch strings.ToLower(s) {
+	case "null", "true", "false", "yes", "no", "on", "off", "y", "n":
+		return strconv.Quote(s)
+	}
+	for i, r := range s {
+		switch {
+		case unicode.IsLetter(r) || r == '_':
+		case i > 0 && (unicode.IsDigit(r) || r == ' ' || r == '-' || r == '.' || r == '/'):
+		default:
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
+
+// encodeCSV 结构体切片首行为字段名，字段名取 csv 标签；[][]string 原样写入
+func encodeCSV(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if records, ok := v.([][]string); ok {
+		if err := w.WriteAll(records); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	rv := indirect(reflect.ValueOf(v))
+	if !rv.IsValid() || rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, errors.New("csv: data must be a slice of structs")
+	}
+	et := rv.Type().Elem()
+	for et.Kind() == reflect.Pointer {
+		et = et.Elem()
+	}
+	if et.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("csv: unsupported element type %s", et)
+	}
+	fields := encodeFields(et, "csv")
+	record := make([]string, len(fields))
+	for i, f := range fields {
+		record[i] = f.name
+	}
+	if err := w.Write(record); err != nil {
+		return nil, err
+	}
+	for i := 0; i < rv.Len(); i++ {
+		row := indirect(rv.Index(i))
+		for j, f := range fields {
+			record[j] = ""
+			if !row.IsValid() {
+				continue
+			}
+			s, err := csvValue(fieldByIndex(row, f.index))
+			if err != nil {
+				return nil, fmt.Errorf("csv: row %d field %s: %w", i, f.name, err)
+			}
+			record[j] = s
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// csvValue 单元格文本，空指针为空串
+func csvValue(v reflect.Value) (string, error) {
+	v = indirect(v)
+	if !v.IsValid() {
+		return "", nil
+	}
+	if s, ok, err := marshalText(v); ok || err != nil {
+		return s, err
+	}
+	switch v.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.String:
+		if v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64 {
+			return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits()), nil
+		}
+		return fmt.Sprint(v.Interface()), nil
+	}
+	return "", fmt.Errorf("unsupported type %s", v.Type())
+}
+
+// encodeMsgPack 将数据编码为 MessagePack，结构体编码为 map，time.Time 使用时间戳扩展
+func encodeMsgPack(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeMsgPack(&buf, reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeMsgPack(buf *bytes.Buffer, v reflect.Value) error {
+	v = indirect(v)
+	if !v.IsValid() {
+		buf.WriteByte(0xc0)
+		return nil
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		// timestamp 96：ext 8，类型 -1，纳秒 uint32 + 秒 int64
+		buf.Write([]byte{0xc7, 12, 0xff})
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(t.Nanosecond())))
+		buf.Write(binary.BigEndian.AppendUint64(nil, uint64(t.Unix())))
+		return nil
+	}
+	if s, ok, err := marshalText(v); ok || err != nil {
+		if err != nil {
+			return err
+		}
+		writeMsgPackString(buf, s)
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		writeMsgPackInt(buf, v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		writeMsgPackUint(buf, v.Uint())
+	case reflect.Float32:
+		buf.WriteByte(0xca)
+		buf.Write(binary.BigEndian.AppendUint32(nil, math.Float32bits(float32(v.Float()))))
+	case reflect.Float64:
+		buf.WriteByte(0xcb)
+		buf.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(v.Float())))
+	case reflect.String:
+		writeMsgPackString(buf, v.String())
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			writeMsgPackHeader(buf, len(b), 0, 0xc4, 0xc5, 0xc6)
+			buf.Write(b)
+			return nil
+		}
+		writeMsgPackHeader(buf, v.Len(), 0x90, 0, 0xdc, 0xdd)
+		for i := 0; i < v.Len(); i++ {
+			if err := writeMsgPack(buf, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		writeMsgPackHeader(buf, v.Len(), 0x80, 0, 0xde, 0xdf)
+		for _, k := range sortedKeys(v) {
+			if err := writeMsgPack(buf, k); err != nil {
+				return err
+			}
+			if err := writeMsgPack(buf, v.MapIndex(k)); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		var fields []encodeField
+		var values []reflect.Value
+		for _, f := range encodeFields(v.Type(), "msgpack", "json") {
+			fv := fieldByIndex(v, f.index)
+			if !fv.IsValid() || f.omitempty && fv.IsZero() {
+				continue
+			}
+			fields = append(fields, f)
+			values = append(values, fv)
+		}
+		writeMsgPackHeader(buf, len(fields), 0x80, 0, 0xde, 0xdf)
+		for i, f := range fields {
+			writeMsgPackString(buf, f.name)
+			if err := writeMsgPack(buf, values[i]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// writeMsgPackHeader 按长度选择 fix、8、16、32 位格式，fix 或 b8 为 0 表示无此格式
+func writeMsgPackHeader(buf *bytes.Buffer, n int, fix, b8, b16, b32 byte) {
+	switch {
+	case fix != 0 && n < 16:
+		buf.WriteByte(fix | byte(n))
+	case b8 != 0 && n <= math.MaxUint8:
+		buf.Write([]byte{b8, byte(n)})
+	case n <= math.MaxUint16:
+		buf.WriteByte(b16)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	default:
+		buf.WriteByte(b32)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	}
+}
+
+func writeMsgPackString(buf *bytes.Buffer, s string) {
+	if len(s) < 32 {
+		buf.WriteByte(0xa0 | byte(len(s)))
+	} else {
+		writeMsgPackHeader(buf, len(s), 0, 0xd9, 0xda, 0xdb)
+	}
+	buf.WriteString(s)
+}
+
+func writeMsgPackInt(buf *bytes.Buffer, i int64) {
+	switch {
+	case i >= 0:
+		writeMsgPackUint(buf, uint64(i))
+	case i >= -32:
+		buf.WriteByte(byte(i))
+	case i >= math.MinInt8:
+		buf.Write([]byte{0xd0, byte(i)})
+	case i >= math.MinInt16:
+		buf.WriteByte(0xd1)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(i)))
+	case i >= math.MinInt32:
+		buf.WriteByte(0xd2)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(i)))
+	default:
+		buf.WriteByte(0xd3)
+		buf.Write(binary.BigEndian.AppendUint64(nil, uint64(i)))
+	}
+}
+
+func writeMsgPackUint(buf *bytes.Buffer, u uint64) {
+	switch {
+	case u < 128:
+		buf.WriteByte(byte(u))
+	case u <= math.MaxUint8:
+		buf.Write([]byte{0xcc, byte(u)})
+	case u <= math.MaxUint16:
+		buf.WriteByte(0xcd)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(u)))
+	case u <= math.MaxUint32:
+		buf.WriteByte(0xce)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(u)))
+	default:
+		buf.WriteByte(0xcf)
+		buf.Write(binary.BigEndian.AppendUint64(nil, u))
+	}
+}
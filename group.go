@@ -0,0 +1,135 @@
+package whttp
+
+import (
+	"net/http"
+	"strings"
+)
+
+// RouteGroup 路由组，共享路径前缀与中间件
+type RouteGroup struct {
+	route       *WRoute
+	prefix      string
+	middlewares []func(*HTTPContext)
+}
+
+// Group 新建子路由组，前缀与中间件在当前组的基础上叠加
+func (g *RouteGroup) Group(prefix string, mw ...func(*HTTPContext)) *RouteGroup {
+	for _, v := range mw {
+		if v == nil {
+			panic("middleware cannot be nil")
+		}
+	}
+	return &RouteGroup{
+		route:       g.route,
+		prefix:      joinPath(g.prefix, prefix),
+		middlewares: g.combine(mw),
+	}
+}
+
+// Prefix 路由组的路径前缀
+func (g *RouteGroup) Prefix() string {
+	return g.prefix
+}
+
+// joinPath 拼接路径前缀，保留 pattern 末尾的 "/"
+func joinPath(prefix, pattern string) string {
+	if len(prefix) == 0 {
+		return pattern
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+	if len(pattern) == 0 {
+		return prefix
+	}
+	return prefix + "/" + strings.TrimPrefix(pattern, "/")
+}
+
+// combine 组中间件在前，返回新切片避免共享底层数组
+func (g *RouteGroup) combine(fn []func(*HTTPContext)) []func(*HTTPContext) {
+	chain := make([]func(*HTTPContext), 0, len(g.middlewares)+len(fn))
+	chain = append(chain, g.middlewares...)
+	return append(chain, fn...)
+}
+
+// handle 注册到所属路由
+func (g *RouteGroup) handle(method, pattern string, fn []func(*HTTPContext)) *Route {
+	if len(fn) == 0 {
+		panic("handler cannot be empty")
+	}
+	pattern = joinPath(g.prefix, pattern)
+	g.route.handle(method, pattern, g.combine(fn))
+	return &Route{route: g.route, Pattern: pattern}
+}
+
+// GET 注册GET方法
+func (g *RouteGroup) GET(pattern string, fn ...func(*HTTPContext)) *Route {
+	return g.handle(http.MethodGet, pattern, fn)
+}
+
+// POST 注册POST方法
+func (g *RouteGroup) POST(pattern string, fn ...func(*HTTPContext)) *Route {
+	return g.handle(http.MethodPost, pattern, fn)
+}
+
+// PUT 注册PUT方法
+func (g *RouteGroup) PUT(pattern string, fn ...func(*HTTPContext)) *Route {
+	return g.handle(http.MethodPut, pattern, fn)
+}
+
+// DELETE 注册DELETE方法
+func (g *RouteGroup) DELETE(pattern string, fn ...func(*HTTPContext)) *Route {
+	return g.handle(http.MethodDelete, pattern, fn)
+}
+
+// HEAD 注册HEAD方法
+func (g *RouteGroup) HEAD(pattern string, fn ...func(*HTTPContext)) *Route {
+	return g.handle(http.MethodHead, pattern, fn)
+}
+
+// PATCH 注册PATCH方法
+func (g *RouteGroup) PATCH(pattern string, fn ...func(*HTTPContext)) *Route {
+	return g.handle(http.MethodPatch, pattern, fn)
+}
+
+// OPTIONS 注册OPTIONS方法
+func (g *RouteGroup) OPTIONS(pattern string, fn ...func(*HTTPContext)) *Route {
+	return g.handle(http.MethodOptions, pattern, fn)
+}
+
+// CONNECT 注册CONNECT方法
+func (g *RouteGroup) CONNECT(pattern string, fn ...func(*HTTPContext)) *Route {
+	return g.handle(http.MethodConnect, pattern, fn)
+}
+
+// TRACE 注册TRACE方法
+func (g *RouteGroup) TRACE(pattern string, fn ...func(*HTTPContext)) *Route {
+	return g.handle(http.MethodTrace, pattern, fn)
+}
+
+// anyMethods Any 注册的方法，HEAD 由 GET 自动应答
+var anyMethods = []string{
+	http.MethodGet,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodConnect,
+	http.MethodOptions,
+	http.MethodTrace,
+}
+
+// Any 注册全部方法
+func (g *RouteGroup) Any(pattern string, fn ...func(*HTTPContext)) *Route {
+	return g.Match(anyMethods, pattern, fn...)
+}
+
+// Match 注册指定的多个方法
+func (g *RouteGroup) Match(methods []string, pattern string, fn ...func(*HTTPContext)) *Route {
+	if len(methods) == 0 {
+		panic("methods cannot be empty")
+	}
+	var rt *Route
+	for _, m := range methods {
+		rt = g.handle(m, pattern, fn)
+	}
+	return rt
+}
@@ -1,40 +1,15 @@
 package whttp
 
-import (
-	"bytes"
-	"compress/gzip"
-	"io"
-	"strings"
-)
+import "compress/gzip"
 
+// GZIPMiddleware 使用 gzip 压缩响应，即只启用 gzip 编码、不限制大小的 CompressMiddleware
 func GZIPMiddleware(level int) func(*HTTPContext) {
-	return func(c *HTTPContext) {
-		if strings.Contains(c.Request.Header.Get("Accept-Encoding"), "gzip") {
-			f := func(b *bytes.Buffer) *bytes.Buffer {
-				c.Writer.Header().Set("Content-Encoding", "gzip")
-				c.Writer.Header().Set("Vary", "Accept-Encoding")
-				buf := new(bytes.Buffer)
-				gzip, err := gzip.NewWriterLevel(buf, level)
-				if err != nil {
-					c.Error("gzipMiddleware new failed", "error", err)
-					return nil
-				}
-				_, err = io.Copy(gzip, b)
-				if err != nil {
-					c.Error("gzipMiddleware copy failed", "error", err)
-					return nil
-				}
-				err = gzip.Close()
-				if err != nil {
-					c.Error("gzipMiddleware close failed", "error", err)
-					return nil
-				}
-
-				return buf
-			}
-			c.HookBeforWriteHeader = append(c.HookBeforWriteHeader, f)
-		}
-		c.Next()
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		panic("gzipMiddleware: invalid compression level")
 	}
-
+	// CompressConfig.Level 为 0 时是缺省级别
+	if level == gzip.NoCompression {
+		level = CompressLevelZero
+	}
+	return CompressMiddleware(CompressConfig{Level: level, Encodings: []string{"gzip"}, MinSize: -1})
 }
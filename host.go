@@ -0,0 +1,105 @@
+package whttp
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// virtualHost 虚拟主机，labels 中形如 {name} 的标签匹配任意一级子域名
+type virtualHost struct {
+	pattern string
+	labels  []string
+	sub     *WRoute
+}
+
+// match 匹配主机名，返回通配标签的取值
+func (v *virtualHost) match(host string) (map[string]string, bool) {
+	labels := strings.Split(host, ".")
+	if len(labels) != len(v.labels) {
+		return nil, false
+	}
+	var params map[string]string
+	for i, l := range v.labels {
+		if len(l) > 2 && l[0] == '{' && l[len(l)-1] == '}' {
+			if len(labels[i]) == 0 {
+				return nil, false
+			}
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[l[1:len(l)-1]] = labels[i]
+			continue
+		}
+		if !strings.EqualFold(l, labels[i]) {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// mount 挂载的子路由
+type mount struct {
+	prefix string
+	sub    *WRoute
+}
+
+// Host 按主机名将请求分发给子路由，支持 example.com 与 {tenant}.example.com，
+// 精确匹配优先，通配标签的取值可通过 c.Param 读取
+func (r *WRoute) Host(pattern string, sub *WRoute) {
+	if len(pattern) == 0 || sub == nil || sub == r {
+		panic("host pattern and sub route cannot be empty")
+	}
+	pattern = strings.ToLower(pattern)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, v := range r.hosts {
+		if v.pattern == pattern {
+			panic("host " + pattern + " already registered")
+		}
+	}
+	v := virtualHost{pattern: pattern, labels: strings.Split(pattern, "."), sub: sub}
+	if strings.Contains(pattern, "{") {
+		r.hosts = append(r.hosts, v)
+	} else {
+		// 精确匹配排在通配之前
+		i := 0
+		for i < len(r.hosts) && !strings.Contains(r.hosts[i].pattern, "{") {
+			i++
+		}
+		r.hosts = append(r.hosts[:i], append([]virtualHost{v}, r.hosts[i:]...)...)
+	}
+}
+
+// Mount 将子路由挂载到 prefix 下，子路由看到的是去掉前缀后的路径，
+// 父、子路由的中间件、模板与日志互相独立
+func (r *WRoute) Mount(prefix string, sub *WRoute) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if len(prefix) == 0 || sub == nil || sub == r {
+		panic("mount prefix and sub route cannot be empty")
+	}
+	r.mu.Lock()
+	r.mounts = append(r.mounts, mount{prefix: prefix, sub: sub})
+	r.mu.Unlock()
+	r.Mux.Handle(prefix+"/", http.StripPrefix(prefix, sub))
+}
+
+// matchHost 查找请求对应的虚拟主机
+func (r *WRoute) matchHost(req *http.Request) (*WRoute, map[string]string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.hosts) == 0 {
+		return nil, nil
+	}
+	host := req.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(host, ".")
+	for i := range r.hosts {
+		if params, ok := r.hosts[i].match(host); ok {
+			return r.hosts[i].sub, params
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,66 @@
+package whttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHost(t *testing.T) {
+	r := NewRoute(nil)
+	r.GET("/", func(c *HTTPContext) { c.String(http.StatusOK, "main") })
+	www := NewRoute(nil)
+	www.GET("/", func(c *HTTPContext) { c.String(http.StatusOK, "www") })
+	tenant := NewRoute(nil)
+	tenant.GET("/", func(c *HTTPContext) { c.String(http.StatusOK, "tenant:"+c.Param("tenant")) })
+	r.Host("{tenant}.example.com", tenant)
+	r.Host("www.example.com", www)
+	tests := [][2]string{
+		{"www.example.com", "www"},
+		{"WWW.example.com:8080", "www"},
+		{"acme.example.com", "tenant:acme"},
+		{"a.b.example.com", "main"},
+		{"example.com", "main"},
+	}
+	for i := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = tests[i][0]
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Body.String() != tests[i][1] {
+			t.Errorf("%s expected %s got %s", tests[i][0], tests[i][1], rec.Body.String())
+		}
+	}
+}
+
+func TestMount(t *testing.T) {
+	r := NewRoute(nil)
+	r.Use(func(c *HTTPContext) {
+		c.Writer.Header().Set("X-App", "main")
+		c.Next()
+	})
+	admin := NewRoute(nil)
+	admin.Use(func(c *HTTPContext) {
+		c.Writer.Header().Set("X-App", "admin")
+		c.Next()
+	})
+	admin.GET("/users/{id}", func(c *HTTPContext) { c.String(http.StatusOK, c.Request.URL.Path+":"+c.Param("id")) })
+	r.Mount("/admin/", admin)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/admin/users/7", nil))
+	if rec.Body.String() != "/users/7:7" {
+		t.Errorf("got %s | expected /users/7:7", rec.Body.String())
+	}
+	if rec.Header().Get("X-App") != "admin" {
+		t.Errorf("got %s | expected admin", rec.Header().Get("X-App"))
+	}
+	found := false
+	for _, v := range r.Routes() {
+		if v.Pattern == "/admin/users/{id}" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("mounted routes not listed")
+	}
+}
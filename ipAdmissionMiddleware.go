@@ -117,6 +117,9 @@ func (f *IPAdmission) Check(line string) bool {
 	return ok
 }
 
+// ErrIPBlocked 客户端 IP 未获准访问
+var ErrIPBlocked = errors.New("no access")
+
 // WhitelistMiddleware 白名单。
 func (f *IPAdmission) WhitelistMiddleware() func(*HTTPContext) {
 	return func(c *HTTPContext) {
@@ -125,7 +128,7 @@ func (f *IPAdmission) WhitelistMiddleware() func(*HTTPContext) {
 			c.Next()
 		} else {
 			c.Warn("IP blocked", "ip", ip)
-			c.String(http.StatusForbidden, "No access")
+			c.AbortWithError(http.StatusForbidden, ErrIPBlocked)
 		}
 	}
 }
@@ -138,7 +141,7 @@ func (f *IPAdmission) BlacklistMiddleware() func(*HTTPContext) {
 			c.Next()
 		} else {
 			c.Warn("IP blocked", "ip", ip)
-			c.String(http.StatusForbidden, "No access")
+			c.AbortWithError(http.StatusForbidden, ErrIPBlocked)
 		}
 	}
 }
@@ -10,6 +10,13 @@ import (
 	"maps"
 )
 
+// JWTMiddleware 认证失败时交由 AbortWithError 的错误
+var (
+	ErrTokenMissing = errors.New("missing token")
+	ErrTokenInvalid = errors.New("token invalid")
+	ErrTokenClaims  = errors.New("the token lacks necessary information")
+)
+
 // JWT
 type JWT struct {
 	TokenSigningKey []byte
@@ -83,17 +90,18 @@ func (j JWT) JWTMiddleware(requiredClaims ...string) func(*HTTPContext) {
 	return func(c *HTTPContext) {
 		authHeader := c.Request.Header.Get("Authorization")
 		if len(authHeader) == 0 {
-			c.String(http.StatusUnauthorized, "Missing token")
+			c.AbortWithError(http.StatusUnauthorized, ErrTokenMissing)
 			return
 		}
 		claims, err := j.TokenParse(authHeader)
 		if err != nil {
-			c.String(http.StatusUnauthorized, "Token invalid")
+			c.Warn("JWTMiddleware", "error", err.Error())
+			c.AbortWithError(http.StatusUnauthorized, ErrTokenInvalid)
 			return
 		}
 		for _, v := range requiredClaims {
 			if a, ok := claims[v]; !ok {
-				c.String(http.StatusUnauthorized, "The token lacks necessary information")
+				c.AbortWithError(http.StatusUnauthorized, ErrTokenClaims)
 				return
 			} else {
 				c.Set(v, a)
@@ -41,8 +41,9 @@ func TestJWTMiddleware(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if !strings.EqualFold(string(data), "Missing token") {
-		t.Fatalf("expected %v got %v", "Missing token", string(data))
+	if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("Content-Type") != MIMEApplicationProblemJSON ||
+		!strings.Contains(string(data), `"detail":"missing token"`) {
+		t.Fatalf("expected %v got %d %v", "missing token", resp.StatusCode, string(data))
 	}
 	//带token
 	claims := map[string]any{"id": 1920}
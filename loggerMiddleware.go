@@ -1,7 +1,6 @@
 package whttp
 
 import (
-	"bytes"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -13,12 +12,6 @@ var formatLogger string = "| %-13v | %-15s | %3d | %-7s | %-40s | %7d bytes "
 // LoggerMiddleware 日志
 func LoggerMiddleware() func(*HTTPContext) {
 	return func(c *HTTPContext) {
-		var n int
-		f := func(b *bytes.Buffer) *bytes.Buffer {
-			n = b.Len()
-			return b
-		}
-		c.HookBeforWriteHeader = append(c.HookBeforWriteHeader, f)
 		startTime := time.Now()
 		c.Next()
 		latency := time.Since(startTime)
@@ -26,13 +19,14 @@ func LoggerMiddleware() func(*HTTPContext) {
 		if latency > time.Minute {
 			latency = latency.Truncate(time.Millisecond)
 		}
+		status, n := c.Status(), c.BytesWritten()
 		switch {
-		case c.status >= http.StatusInternalServerError:
-			slog.Error(fmt.Sprintf(formatLogger, latency, c.Request.RemoteAddr, c.status, c.Request.Method, c.Request.URL, n))
-		case c.status >= http.StatusBadRequest:
-			slog.Warn(fmt.Sprintf(formatLogger, latency, c.Request.RemoteAddr, c.status, c.Request.Method, c.Request.URL, n))
+		case status >= http.StatusInternalServerError:
+			slog.Error(fmt.Sprintf(formatLogger, latency, c.Request.RemoteAddr, status, c.Request.Method, c.Request.URL, n))
+		case status >= http.StatusBadRequest:
+			slog.Warn(fmt.Sprintf(formatLogger, latency, c.Request.RemoteAddr, status, c.Request.Method, c.Request.URL, n))
 		default:
-			slog.Debug(fmt.Sprintf(formatLogger, latency, c.Request.RemoteAddr, c.status, c.Request.Method, c.Request.URL, n))
+			slog.Debug(fmt.Sprintf(formatLogger, latency, c.Request.RemoteAddr, status, c.Request.Method, c.Request.URL, n))
 		}
 	}
 }
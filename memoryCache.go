@@ -0,0 +1,312 @@
+package whttp
+
+import (
+	"container/heap"
+	"container/list"
+	"hash/maphash"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ExtendedCache 支持有效期与标签的缓存，CacheMiddleware 检测到时按响应的有效期存入，并附加 CacheConfig.Tags 的标签
+type ExtendedCache interface {
+	Cache
+	// SetWithTTL ttl 为 0 时不过期
+	SetWithTTL(key string, value []byte, ttl time.Duration, tags ...string)
+	// InvalidateTag 删除带有 tag 的全部键，返回删除的数量
+	InvalidateTag(tag string) int
+}
+
+// memoryCacheShards MemoryCache 的分片数
+const memoryCacheShards = 16
+
+// CacheStats MemoryCache 的统计
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	// 过期后被删除的数量
+	Expirations uint64
+	Entries     int
+	Bytes       int
+}
+
+// MemoryCache 分片的内存缓存，实现 ExtendedCache。
+// 全部键与值的字节数之和超出预算时，先删除过期的键，仍超出时淘汰各分片最久未用的项中最旧的一项
+type MemoryCache struct {
+	seed     maphash.Seed
+	shards   [memoryCacheShards]memoryShard
+	maxBytes int64
+	bytes    atomic.Int64
+	// 访问的序号，记录各项最近使用的先后
+	clock       atomic.Uint64
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+	evictions   atomic.Uint64
+	expirations atomic.Uint64
+}
+
+var _ ExtendedCache = (*MemoryCache)(nil)
+
+// lookupCache 查找时不计入统计，由 cacheLookup 按一次逻辑查找记录命中，
+// 避免经过 Vary 索引的查找计为两次
+type lookupCache interface {
+	lookup(dst []byte, key string) ([]byte, bool)
+	record(hit bool)
+}
+
+// NewMemoryCache maxBytes 为全部键与值的字节数上限，由各分片共享
+func NewMemoryCache(maxBytes int) *MemoryCache {
+	if maxBytes <= 0 {
+		panic("memoryCache: maxBytes must be positive")
+	}
+	m := &MemoryCache{seed: maphash.MakeSeed(), maxBytes: int64(maxBytes)}
+	for i := range m.shards {
+		m.shards[i] = memoryShard{
+			m:     m,
+			items: make(map[string]*list.Element),
+			tags:  make(map[string]map[string]struct{}),
+		}
+	}
+	return m
+}
+
+func (m *MemoryCache) shard(key string) *memoryShard {
+	return &m.shards[maphash.String(m.seed, key)%memoryCacheShards]
+}
+
+// Set 存入不过期的值
+func (m *MemoryCache) Set(key string, value []byte) {
+	m.SetWithTTL(key, value, 0)
+}
+
+// SetWithTTL 存入 value 的副本，键与值的字节数之和大于预算时不存入
+func (m *MemoryCache) SetWithTTL(key string, value []byte, ttl time.Duration, tags ...string) {
+	var expires time.Time
+	if ttl > 0 {
+		expires = time.Now().Add(ttl)
+	}
+	m.shard(key).set(key, append([]byte(nil), value...), expires, tags)
+	m.evict()
+}
+
+// evict 超出预算时删除过期的键，仍超出时淘汰各分片最久未用的项中最旧的一项，直至不超出
+func (m *MemoryCache) evict() {
+	for m.bytes.Load() > m.maxBytes {
+		now := time.Now()
+		var oldest *memoryShard
+		var used uint64
+		for i := range m.shards {
+			s := &m.shards[i]
+			s.mu.Lock()
+			s.purge(now)
+			if e := s.lru.Back(); e != nil {
+				if it := e.Value.(*memoryItem); oldest == nil || it.used < used {
+					oldest, used = s, it.used
+				}
+			}
+			s.mu.Unlock()
+		}
+		if oldest == nil || m.bytes.Load() <= m.maxBytes {
+			return
+		}
+		oldest.mu.Lock()
+		// 加锁前可能已被访问或删除，仍淘汰该分片最久未用的项
+		if e := oldest.lru.Back(); e != nil {
+			m.evictions.Add(1)
+			oldest.remove(e)
+		}
+		oldest.mu.Unlock()
+	}
+}
+
+// HasGet 将值追加到 dst 后返回
+func (m *MemoryCache) HasGet(dst []byte, key string) ([]byte, bool) {
+	b, ok := m.lookup(dst, key)
+	m.record(ok)
+	return b, ok
+}
+
+func (m *MemoryCache) lookup(dst []byte, key string) ([]byte, bool) {
+	return m.shard(key).get(dst, key)
+}
+
+func (m *MemoryCache) record(hit bool) {
+	if hit {
+		m.hits.Add(1)
+	} else {
+		m.misses.Add(1)
+	}
+}
+
+func (m *MemoryCache) Del(key string) {
+	s := m.shard(key)
+	s.mu.Lock()
+	if e, ok := s.items[key]; ok {
+		s.remove(e)
+	}
+	s.mu.Unlock()
+}
+
+func (m *MemoryCache) InvalidateTag(tag string) int {
+	n := 0
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.Lock()
+		for key := range s.tags[tag] {
+			s.remove(s.items[key])
+			n++
+		}
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// Stats 统计的快照
+func (m *MemoryCache) Stats() CacheStats {
+	st := CacheStats{
+		Hits:        m.hits.Load(),
+		Misses:      m.misses.Load(),
+		Evictions:   m.evictions.Load(),
+		Expirations: m.expirations.Load(),
+		Bytes:       int(m.bytes.Load()),
+	}
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.Lock()
+		st.Entries += len(s.items)
+		s.mu.Unlock()
+	}
+	return st
+}
+
+// memoryShard 一个分片，lru 靠前的最近使用
+type memoryShard struct {
+	m     *MemoryCache
+	mu    sync.Mutex
+	lru   list.List
+	items map[string]*list.Element
+	// 设置了有效期的项
+	exp expiryHeap
+	// 标签到键
+	tags map[string]map[string]struct{}
+}
+
+type memoryItem struct {
+	key     string
+	value   []byte
+	expires time.Time
+	tags    []string
+	// 最近使用时的 clock
+	used uint64
+	// 在 expiryHeap 中的位置，不过期时为 -1
+	index int
+}
+
+func (it *memoryItem) size() int {
+	return len(it.key) + len(it.value)
+}
+
+func (it *memoryItem) expired(now time.Time) bool {
+	return !it.expires.IsZero() && !now.Before(it.expires)
+}
+
+func (s *memoryShard) set(key string, value []byte, expires time.Time, tags []string) {
+	it := &memoryItem{key: key, value: value, expires: expires, tags: tags, used: s.m.clock.Add(1), index: -1}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.items[key]; ok {
+		s.remove(e)
+	}
+	if int64(it.size()) > s.m.maxBytes {
+		return
+	}
+	s.items[key] = s.lru.PushFront(it)
+	if !expires.IsZero() {
+		heap.Push(&s.exp, it)
+	}
+	s.m.bytes.Add(int64(it.size()))
+	for _, tag := range tags {
+		keys, ok := s.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			s.tags[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+}
+
+func (s *memoryShard) get(dst []byte, key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.items[key]
+	if !ok {
+		return dst, false
+	}
+	it := e.Value.(*memoryItem)
+	if it.expired(time.Now()) {
+		s.remove(e)
+		s.m.expirations.Add(1)
+		return dst, false
+	}
+	s.lru.MoveToFront(e)
+	it.used = s.m.clock.Add(1)
+	return append(dst, it.value...), true
+}
+
+// purge 删除已过期的项，需持有锁
+func (s *memoryShard) purge(now time.Time) {
+	for len(s.exp) > 0 && s.exp[0].expired(now) {
+		s.remove(s.items[s.exp[0].key])
+		s.m.expirations.Add(1)
+	}
+}
+
+// remove 需持有锁
+func (s *memoryShard) remove(e *list.Element) {
+	it := s.lru.Remove(e).(*memoryItem)
+	if it.index >= 0 {
+		heap.Remove(&s.exp, it.index)
+	}
+	delete(s.items, it.key)
+	s.m.bytes.Add(-int64(it.size()))
+	for _, tag := range it.tags {
+		delete(s.tags[tag], it.key)
+		if len(s.tags[tag]) == 0 {
+			delete(s.tags, tag)
+		}
+	}
+}
+
+// expiryHeap 按过期时间排列的小顶堆
+type expiryHeap []*memoryItem
+
+func (h expiryHeap) Len() int {
+	return len(h)
+}
+
+func (h expiryHeap) Less(i, j int) bool {
+	return h[i].expires.Before(h[j].expires)
+}
+
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *expiryHeap) Push(x any) {
+	it := x.(*memoryItem)
+	it.index = len(*h)
+	*h = append(*h, it)
+}
+
+func (h *expiryHeap) Pop() any {
+	old := *h
+	it := old[len(old)-1]
+	old[len(old)-1] = nil
+	it.index = -1
+	*h = old[:len(old)-1]
+	return it
+}
@@ -0,0 +1,138 @@
+package whttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMemoryCache(t *testing.T) {
+	m := NewMemoryCache(100)
+	keys := []string{"k0", "k1", "k2", "k3"}
+	value := make([]byte, 25)
+	for _, k := range keys[:3] {
+		m.Set(k, value)
+	}
+	// 访问 keys[0] 后 keys[1] 最久未用，各键通常位于不同的分片
+	if b, ok := m.HasGet([]byte("x"), keys[0]); !ok || len(b) != 26 {
+		t.Errorf("got %d %v | expected 26 true", len(b), ok)
+	}
+	m.Set(keys[3], value)
+	for i, expected := range []bool{true, false, true, true} {
+		if _, ok := m.HasGet(nil, keys[i]); ok != expected {
+			t.Errorf("%s: got %v | expected %v", keys[i], ok, expected)
+		}
+	}
+	// 大于预算的值不存入
+	m.Set("large", make([]byte, 101))
+	if _, ok := m.HasGet(nil, "large"); ok {
+		t.Error("got large cached | expected not cached")
+	}
+	m.Del(keys[0])
+	if _, ok := m.HasGet(nil, keys[0]); ok {
+		t.Error("got deleted key | expected miss")
+	}
+	st := m.Stats()
+	if st.Hits != 4 || st.Misses != 3 || st.Evictions != 1 || st.Entries != 2 || st.Bytes != len(keys[2])+len(keys[3])+50 {
+		t.Errorf("got %+v | expected 4 hits, 3 misses, 1 eviction, 2 entries", st)
+	}
+	// 预算由各分片共享，可存入大于预算 1/16 的值
+	m = NewMemoryCache(16 << 20)
+	m.Set("1MiB", make([]byte, 1<<20))
+	if _, ok := m.HasGet(nil, "1MiB"); !ok {
+		t.Error("got 1 MiB value not cached | expected cached")
+	}
+}
+
+func TestMemoryCacheTTLAndTags(t *testing.T) {
+	m := NewMemoryCache(1 << 20)
+	m.SetWithTTL("short", []byte("1"), 10*time.Millisecond)
+	m.SetWithTTL("a", []byte("1"), 0, "user:42", "list")
+	m.SetWithTTL("b", []byte("1"), time.Minute, "user:42")
+	m.SetWithTTL("c", []byte("1"), time.Minute, "user:7")
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := m.HasGet(nil, "short"); ok {
+		t.Error("got expired key | expected miss")
+	}
+	if n := m.InvalidateTag("user:42"); n != 2 {
+		t.Errorf("got %d | expected 2", n)
+	}
+	for key, expected := range map[string]bool{"a": false, "b": false, "c": true} {
+		if _, ok := m.HasGet(nil, key); ok != expected {
+			t.Errorf("%s: got %v | expected %v", key, ok, expected)
+		}
+	}
+	if n := m.InvalidateTag("list"); n != 0 {
+		t.Errorf("got %d | expected 0", n)
+	}
+	if st := m.Stats(); st.Expirations != 1 || st.Entries != 1 {
+		t.Errorf("got %+v | expected 1 expiration, 1 entry", st)
+	}
+}
+
+func TestMemoryCacheExpiredFirst(t *testing.T) {
+	m := NewMemoryCache(100)
+	value := make([]byte, 28)
+	m.Set("old", value)
+	m.SetWithTTL("ttl", value, 10*time.Millisecond)
+	m.Set("new", value)
+	time.Sleep(20 * time.Millisecond)
+	// 超出预算时先删除过期的键，而非最久未用的键
+	m.Set("more", value)
+	for key, expected := range map[string]bool{"old": true, "ttl": false, "new": true, "more": true} {
+		if _, ok := m.HasGet(nil, key); ok != expected {
+			t.Errorf("%s: got %v | expected %v", key, ok, expected)
+		}
+	}
+	if st := m.Stats(); st.Expirations != 1 || st.Evictions != 0 {
+		t.Errorf("got %+v | expected 1 expiration, 0 evictions", st)
+	}
+}
+
+func TestCacheMiddlewareStats(t *testing.T) {
+	m := NewMemoryCache(1 << 20)
+	r := NewRoute(nil)
+	r.GET("/", CacheMiddleware(m, nil), func(c *HTTPContext) {
+		c.Writer.Header().Set("Vary", "Accept-Language")
+		c.String(http.StatusOK, "hello")
+	})
+	for _, lang := range []string{"en", "en", "zh"} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Language", lang)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	// 经过 Vary 索引的查找只计一次
+	if st := m.Stats(); st.Hits != 1 || st.Misses != 2 {
+		t.Errorf("got %+v | expected 1 hit, 2 misses", st)
+	}
+}
+
+func TestCacheMiddlewareExtended(t *testing.T) {
+	m := NewMemoryCache(1 << 20)
+	calls := 0
+	r := NewRoute(nil)
+	r.GET("/user/{id}", CacheMiddlewareWithConfig(CacheConfig{
+		Cache: m,
+		Tags: func(c *HTTPContext) []string {
+			return []string{"user:" + c.Param("id")}
+		},
+	}), func(c *HTTPContext) {
+		calls++
+		c.Writer.Header().Set("Cache-Control", "max-age=60")
+		c.String(http.StatusOK, c.Param("id"))
+	})
+	get := func(path string) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+	}
+	get("/user/42")
+	get("/user/42")
+	get("/user/7")
+	m.InvalidateTag("user:42")
+	get("/user/42")
+	get("/user/7")
+	if calls != 3 {
+		t.Errorf("got %d calls | expected 3", calls)
+	}
+}
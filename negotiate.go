@@ -0,0 +1,161 @@
+package whttp
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Encoder 将数据编码为响应体
+type Encoder func(any) ([]byte, error)
+
+// encoders 按 MIME 类型注册的编码器，offers 为协商时的优先顺序
+var (
+	encoders = map[string]Encoder{
+		MIMEApplicationJSON:    func(v any) ([]byte, error) { return DefaultMarshal(v) },
+		MIMEApplicationXML:     xml.Marshal,
+		MIMEApplicationYAML:    encodeYAML,
+		MIMETextCSV:            encodeCSV,
+		MIMEApplicationMsgPack: encodeMsgPack,
+	}
+	offers = []string{MIMEApplicationJSON, MIMEApplicationXML, MIMEApplicationYAML, MIMETextCSV, MIMEApplicationMsgPack}
+)
+
+// RegisterEncoder 注册或替换某一 MIME 类型的编码器，需在服务启动前调用
+func RegisterEncoder(mimeType string, enc Encoder) {
+	if len(mimeType) == 0 || enc == nil {
+		panic("encoder mime type and function cannot be empty")
+	}
+	if _, ok := encoders[mimeType]; !ok {
+		offers = append(offers, mimeType)
+	}
+	encoders[mimeType] = enc
+}
+
+// Encode 使用注册的编码器返回带有状态码的数据，编码失败时记录日志并返回 500
+func (c *HTTPContext) Encode(status int, mimeType string, v any) {
+	c.alive()
+	enc, ok := encoders[mimeType]
+	if !ok {
+		c.Error("encode", "error", "no encoder for "+mimeType)
+		c.String(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+		return
+	}
+	data, err := enc(v)
+	if err != nil {
+		// 编码错误可能含有内部信息，只写入日志
+		c.Error("encode", "mime", mimeType, "error", err.Error())
+		c.String(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+		return
+	}
+	c.Writer.Header().Set("Content-Type", mimeType)
+	c.write(status, data)
+}
+
+// XML 带有状态码的 XML 数据
+func (c *HTTPContext) XML(status int, v any) {
+	c.Encode(status, MIMEApplicationXML, v)
+}
+
+// YAML 带有状态码的 YAML 数据
+func (c *HTTPContext) YAML(status int, v any) {
+	c.Encode(status, MIMEApplicationYAML, v)
+}
+
+// CSV 带有状态码的 CSV 数据，v 为结构体切片或 [][]string
+func (c *HTTPContext) CSV(status int, v any) {
+	c.Encode(status, MIMETextCSV, v)
+}
+
+// MsgPack 带有状态码的 MessagePack 数据
+func (c *HTTPContext) MsgPack(status int, v any) {
+	c.Encode(status, MIMEApplicationMsgPack, v)
+}
+
+// Negotiate 按 Accept 请求头选择已注册的编码器，无可接受的格式时返回 406
+func (c *HTTPContext) Negotiate(status int, v any) {
+	c.alive()
+	addVary(c.Writer.Header(), "Accept")
+	mimeType := c.NegotiateFormat(offers...)
+	if len(mimeType) == 0 {
+		c.String(http.StatusNotAcceptable, "not acceptable")
+		return
+	}
+	c.Encode(status, mimeType, v)
+}
+
+// NegotiateFormat 从 offered 中选出 Accept 请求头 q 值最高的 MIME 类型，
+// q 值相同时按 offered 的顺序，无 Accept 时返回第一个，均不可接受时返回空串
+func (c *HTTPContext) NegotiateFormat(offered ...string) string {
+	c.alive()
+	if len(offered) == 0 {
+		return ""
+	}
+	accept := parseAccept(c.Request.Header.Values("Accept"))
+	if len(accept) == 0 {
+		return offered[0]
+	}
+	best, bestQ := "", 0.0
+	for _, o := range offered {
+		if q := acceptQuality(accept, o); q > bestQ {
+			best, bestQ = o, q
+		}
+	}
+	return best
+}
+
+// mediaRange Accept 中的一项
+type mediaRange struct {
+	typ, sub string
+	q        float64
+}
+
+// parseAccept 解析 Accept 请求头，忽略格式错误的项
+func parseAccept(values []string) []mediaRange {
+	var ranges []mediaRange
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			media, params, _ := strings.Cut(part, ";")
+			typ, sub, ok := strings.Cut(strings.ToLower(strings.TrimSpace(media)), "/")
+			if !ok || len(typ) == 0 || len(sub) == 0 || typ == "*" && sub != "*" {
+				continue
+			}
+			r := mediaRange{typ: typ, sub: sub, q: 1}
+			for _, p := range strings.Split(params, ";") {
+				k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+				if strings.EqualFold(k, "q") {
+					q, err := strconv.ParseFloat(v, 64)
+					if err != nil || q < 0 || q > 1 {
+						q = 0
+					}
+					r.q = q
+				}
+			}
+			ranges = append(ranges, r)
+		}
+	}
+	return ranges
+}
+
+// acceptQuality 取与 mimeType 匹配的最具体的一项的 q 值
+func acceptQuality(ranges []mediaRange, mimeType string) float64 {
+	media, _, _ := strings.Cut(mimeType, ";")
+	typ, sub, _ := strings.Cut(strings.ToLower(strings.TrimSpace(media)), "/")
+	q, specificity := 0.0, 0
+	for _, r := range ranges {
+		s := 0
+		switch {
+		case r.typ == typ && r.sub == sub:
+			s = 3
+		case r.typ == typ && r.sub == "*":
+			s = 2
+		case r.typ == "*":
+			s = 1
+		}
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+	return q
+}
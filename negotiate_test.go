@@ -0,0 +1,206 @@
+package whttp
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type negotiateItem struct {
+	ID    int       `json:"id" csv:"id" xml:"id"`
+	Name  string    `json:"name" csv:"name" xml:"name"`
+	Tags  []string  `json:"tags,omitempty" csv:"-" xml:"tag"`
+	Price *float64  `json:"price" csv:"price" xml:"price,omitempty"`
+	At    time.Time `json:"-" csv:"-" xml:"-" yaml:"at,omitempty"`
+}
+
+func TestNegotiate(t *testing.T) {
+	r := NewRoute(nil)
+	r.GET("/", func(c *HTTPContext) {
+		c.Negotiate(http.StatusOK, negotiateItem{ID: 1, Name: "a"})
+	})
+	r.GET("/html", func(c *HTTPContext) {
+		c.String(http.StatusOK, c.NegotiateFormat(MIMETextHtml, MIMETextPlain))
+	})
+	r.GET("/vary", func(c *HTTPContext) {
+		c.Writer.Header().Set("Vary", "accept, Origin")
+		c.Negotiate(http.StatusOK, negotiateItem{ID: 1})
+	})
+	tests := []struct {
+		accept string
+		status int
+		ctype  string
+	}{
+		{"", http.StatusOK, MIMEApplicationJSON},
+		{"*/*", http.StatusOK, MIMEApplicationJSON},
+		{"application/xml", http.StatusOK, MIMEApplicationXML},
+		{"application/json;q=0.5, application/yaml", http.StatusOK, MIMEApplicationYAML},
+		{"application/*;q=0.2, application/msgpack;q=0.9, */*;q=0.1", http.StatusOK, MIMEApplicationMsgPack},
+		{"application/*, application/json;q=0", http.StatusOK, MIMEApplicationXML},
+		{"image/png", http.StatusNotAcceptable, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		if len(tt.accept) > 0 {
+			req.Header.Set("Accept", tt.accept)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != tt.status || rec.Header().Get("Content-Type") != tt.ctype {
+			t.Errorf("%s: got %d %s | expected %d %s", tt.accept, rec.Code, rec.Header().Get("Content-Type"), tt.status, tt.ctype)
+		}
+	}
+	for accept, expected := range map[string]string{"text/*;q=0.5, text/plain": MIMETextPlain, "text/html, */*;q=0.1": MIMETextHtml, "": MIMETextHtml} {
+		req := httptest.NewRequest("GET", "/html", nil)
+		req.Header.Set("Accept", accept)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Body.String() != expected {
+			t.Errorf("%s: got %s | expected %s", accept, rec.Body.String(), expected)
+		}
+	}
+	// 已含 Accept 时不重复追加
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/vary", nil))
+	if vary := rec.Header().Values("Vary"); len(vary) != 1 {
+		t.Errorf("got %q | expected accept, Origin", vary)
+	}
+}
+
+func TestEncodeYAML(t *testing.T) {
+	price := 9.5
+	data := map[string]any{
+		"items": []negotiateItem{{ID: 1, Name: "true", Tags: []string{"x", "y: z"}, Price: &price}, {ID: 2, Name: "b"}},
+		"empty": []int{},
+		"grid":  [][]int{{1, 2}, {3}},
+		"note":  "multi\nline",
+	}
+	b, err := encodeYAML(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `empty: []
+grid:
+  - - 1
+    - 2
+  - - 3
+items:
+  - id: 1
+    name: "true"
+    tags:
+      - x
+      - "y: z"
+    price: 9.5
+  - id: 2
+    name: b
+    price: null
+note: "multi\nline"
+`
+	if string(b) != expected {
+		t.Errorf("got %s | expected %s", b, expected)
+	}
+	for s, expected := range map[string]string{
+		"plain":       "plain",
+		"hello world": "hello world",
+		"v1.2-rc/a_b": "v1.2-rc/a_b",
+		"中文":          "中文",
+		"Null":        `"Null"`,
+		"0x1F":        `"0x1F"`,
+		"0o17":        `"0o17"`,
+		"2001-12-14":  `"2001-12-14"`,
+		"12:30":       `"12:30"`,
+		"=":           `"="`,
+		"<<":          `"<<"`,
+		"a: b":        `"a: b"`,
+		"a #b":        `"a #b"`,
+		".inf":        `".inf"`,
+		"-1":          `"-1"`,
+	} {
+		if got := yamlString(s); got != expected {
+			t.Errorf("%s: got %s | expected %s", s, got, expected)
+		}
+	}
+}
+
+type negotiateBase struct {
+	ID int `json:"id"`
+}
+
+type negotiateEmbedded struct {
+	negotiateBase
+	Name string `json:"name"`
+}
+
+func TestEncodeEmbedded(t *testing.T) {
+	// 与 encoding/json 相同，未导出的匿名结构体展开其导出的字段
+	b, err := encodeYAML(negotiateEmbedded{negotiateBase{ID: 1}, "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "id: 1\nname: a\n" {
+		t.Errorf("got %q | expected id and name", b)
+	}
+	b, err = encodeCSV([]negotiateEmbedded{{negotiateBase{ID: 2}, "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "ID,Name\n2,b\n" {
+		t.Errorf("got %q | expected ID,Name", b)
+	}
+}
+
+func TestEncodeCSV(t *testing.T) {
+	price := 1.25
+	b, err := encodeCSV([]*negotiateItem{{ID: 1, Name: "a,b", Price: &price}, nil, {ID: 2, Name: "c"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "id,name,price\n1,\"a,b\",1.25\n,,\n2,c,\n"
+	if string(b) != expected {
+		t.Errorf("got %q | expected %q", b, expected)
+	}
+	if _, err := encodeCSV(map[string]int{}); err == nil {
+		t.Error("got nil | expected error")
+	}
+	// 编码错误不返回给客户端
+	r := NewRoute(nil)
+	r.GET("/", func(c *HTTPContext) {
+		c.CSV(http.StatusOK, map[string]int{})
+	})
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusInternalServerError || rec.Body.String() != "Internal Server Error" {
+		t.Errorf("got %d %s | expected 500 Internal Server Error", rec.Code, rec.Body.String())
+	}
+}
+
+func TestEncodeMsgPack(t *testing.T) {
+	tests := []struct {
+		v        any
+		expected []byte
+	}{
+		{nil, []byte{0xc0}},
+		{true, []byte{0xc3}},
+		{-1, []byte{0xff}},
+		{-200, []byte{0xd1, 0xff, 0x38}},
+		{300, []byte{0xcd, 0x01, 0x2c}},
+		{1.5, []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{"ab", []byte{0xa2, 'a', 'b'}},
+		{[]byte{1, 2}, []byte{0xc4, 2, 1, 2}},
+		{[]int{1, 2}, []byte{0x92, 1, 2}},
+		{map[string]int{"b": 2, "a": 1}, []byte{0x82, 0xa1, 'a', 1, 0xa1, 'b', 2}},
+		{struct {
+			A int    `msgpack:"a"`
+			B string `json:"b,omitempty"`
+		}{A: 1}, []byte{0x81, 0xa1, 'a', 1}},
+		{time.Unix(1, 2), []byte{0xc7, 12, 0xff, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 1}},
+	}
+	for _, tt := range tests {
+		b, err := encodeMsgPack(tt.v)
+		if err != nil || !bytes.Equal(b, tt.expected) {
+			t.Errorf("%v: got %x %v | expected %x", tt.v, b, err, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package whttp
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// paramConstraints 具名的路径参数约束，如 {id:int}
+var paramConstraints = map[string]func(string) bool{
+	"int": func(s string) bool {
+		_, err := strconv.ParseInt(s, 10, 64)
+		return err == nil
+	},
+	"uint": func(s string) bool {
+		_, err := strconv.ParseUint(s, 10, 64)
+		return err == nil
+	},
+	"alpha": isAlpha,
+	"alnum": isAlnum,
+	"uuid":  isUUID,
+}
+
+// RegisterParamConstraint 注册具名的路径参数约束，需在注册路由前调用
+func RegisterParamConstraint(name string, fn func(string) bool) {
+	if len(name) == 0 || fn == nil {
+		panic("param constraint name and function cannot be empty")
+	}
+	paramConstraints[name] = fn
+}
+
+// isAlpha 非空且只含 ASCII 字母
+func isAlpha(s string) bool {
+	return len(s) > 0 && strings.IndexFunc(s, func(r rune) bool {
+		return !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z')
+	}) < 0
+}
+
+// isAlnum 非空且只含 ASCII 字母与数字
+func isAlnum(s string) bool {
+	return len(s) > 0 && strings.IndexFunc(s, func(r rune) bool {
+		return !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9')
+	}) < 0
+}
+
+// isUUID 形如 xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx 的十六进制串
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		switch i {
+		case 8, 13, 18, 23:
+			if s[i] != '-' {
+				return false
+			}
+		default:
+			c := s[i]
+			if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// patternToken 路径模式片段，name 为空时为字面量
+type patternToken struct {
+	literal string
+	name    string
+	multi   bool
+	// 约束，具名约束或正则表达式
+	rule string
+}
+
+// tokenize 拆分路径模式，通配符形如 {name}、{name...}、{name:rule}，rule 内可含成对的花括号
+func tokenize(pattern string) ([]patternToken, error) {
+	var tokens []patternToken
+	for len(pattern) > 0 {
+		i := strings.IndexByte(pattern, '{')
+		if i < 0 {
+			tokens = append(tokens, patternToken{literal: pattern})
+			break
+		}
+		if i > 0 {
+			tokens = append(tokens, patternToken{literal: pattern[:i]})
+		}
+		depth, j := 0, -1
+		for k := i; k < len(pattern) && j < 0; k++ {
+			switch pattern[k] {
+			case '{':
+				depth++
+			case '}':
+				depth--
+				if depth == 0 {
+					j = k
+				}
+			}
+		}
+		if j < 0 {
+			return nil, fmt.Errorf("bad wildcard in pattern %s", pattern)
+		}
+		name, rule, _ := strings.Cut(pattern[i+1:j], ":")
+		tok := patternToken{name: name, rule: rule}
+		if strings.HasSuffix(name, "...") {
+			tok.name = strings.TrimSuffix(name, "...")
+			tok.multi = true
+		}
+		if len(tok.name) == 0 {
+			return nil, fmt.Errorf("empty wildcard name in pattern %s", pattern)
+		}
+		tokens = append(tokens, tok)
+		pattern = pattern[j+1:]
+	}
+	return tokens, nil
+}
+
+// paramRule 路径参数约束
+type paramRule struct {
+	name  string
+	match func(string) bool
+}
+
+// compileRule 优先使用具名约束，否则按正则表达式整体匹配
+func compileRule(rule string) (func(string) bool, error) {
+	if fn, ok := paramConstraints[rule]; ok {
+		return fn, nil
+	}
+	re, err := regexp.Compile("^(?:" + rule + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("bad param constraint %s: %w", rule, err)
+	}
+	return re.MatchString, nil
+}
+
+// compilePattern 去掉约束得到 http.ServeMux 可识别的模式，约束只用于匹配后的校验，不能区分同一路径的路由
+func compilePattern(pattern string) (string, []paramRule, error) {
+	tokens, err := tokenize(pattern)
+	if err != nil {
+		return "", nil, err
+	}
+	var b strings.Builder
+	var rules []paramRule
+	for _, tok := range tokens {
+		if len(tok.name) == 0 {
+			b.WriteString(tok.literal)
+			continue
+		}
+		b.WriteString("{" + tok.name)
+		if tok.multi {
+			b.WriteString("...")
+		}
+		b.WriteString("}")
+		if len(tok.rule) > 0 {
+			fn, err := compileRule(tok.rule)
+			if err != nil {
+				return "", nil, err
+			}
+			rules = append(rules, paramRule{name: tok.name, match: fn})
+		}
+	}
+	return b.String(), rules, nil
+}
+
+// matchRules 路径参数是否全部满足约束
+func matchRules(req *http.Request, rules []paramRule) bool {
+	for _, v := range rules {
+		if !v.match(req.PathValue(v.name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Param 路径参数
+func (c *HTTPContext) Param(name string) string {
+	c.alive()
+	return c.Request.PathValue(name)
+}
+
+// ParamInt 路径参数转为 int
+func (c *HTTPContext) ParamInt(name string) (int, error) {
+	c.alive()
+	v, err := strconv.Atoi(c.Request.PathValue(name))
+	if err != nil {
+		return 0, fmt.Errorf("param %s: %w", name, err)
+	}
+	return v, nil
+}
+
+// ParamInt64 路径参数转为 int64
+func (c *HTTPContext) ParamInt64(name string) (int64, error) {
+	c.alive()
+	v, err := strconv.ParseInt(c.Request.PathValue(name), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("param %s: %w", name, err)
+	}
+	return v, nil
+}
+
+// ParamUUID 路径参数校验为 UUID，返回小写形式
+func (c *HTTPContext) ParamUUID(name string) (string, error) {
+	c.alive()
+	v := c.Request.PathValue(name)
+	if !isUUID(v) {
+		return "", fmt.Errorf("param %s: invalid uuid %q", name, v)
+	}
+	return strings.ToLower(v), nil
+}
@@ -0,0 +1,79 @@
+package whttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestParamConstraint(t *testing.T) {
+	r := NewRoute(nil)
+	r.GET("/users/{id:int}", func(c *HTTPContext) {
+		id, err := c.ParamInt("id")
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		c.String(http.StatusOK, strconv.Itoa(id*2))
+	})
+	r.GET("/posts/{slug:[a-z-]+}", func(c *HTTPContext) { c.String(http.StatusOK, c.Param("slug")) })
+	r.GET("/codes/{code:[0-9]{3}}", func(c *HTTPContext) { c.String(http.StatusOK, c.Param("code")) })
+	r.GET("/items/{uuid:uuid}", func(c *HTTPContext) {
+		v, err := c.ParamUUID("uuid")
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		c.String(http.StatusOK, v)
+	})
+	tests := [][3]any{
+		{"/users/21", http.StatusOK, "42"},
+		{"/users/abc", http.StatusNotFound, "404 page not found"},
+		{"/posts/hello-world", http.StatusOK, "hello-world"},
+		{"/posts/Hello", http.StatusNotFound, "404 page not found"},
+		{"/codes/404", http.StatusOK, "404"},
+		{"/codes/4040", http.StatusNotFound, "404 page not found"},
+		{"/items/3F2504E0-4F89-11D3-9A0C-0305E82C3301", http.StatusOK, "3f2504e0-4f89-11d3-9a0c-0305e82c3301"},
+		{"/items/3F2504E0", http.StatusNotFound, "404 page not found"},
+	}
+	for i := range tests {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("GET", tests[i][0].(string), nil))
+		if rec.Code != tests[i][1].(int) || rec.Body.String() != tests[i][2].(string) {
+			t.Errorf("%s expected %d %s got %d %s", tests[i][0], tests[i][1], tests[i][2], rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestRegisterParamConstraint(t *testing.T) {
+	RegisterParamConstraint("even", func(s string) bool {
+		n, err := strconv.Atoi(s)
+		return err == nil && n%2 == 0
+	})
+	r := NewRoute(nil)
+	r.GET("/even/{n:even}", func(c *HTTPContext) { c.String(http.StatusOK, c.Param("n")) }).Name("even")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/even/3", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("got %d | expected 404", rec.Code)
+	}
+	if u, err := r.URL("even", 4); err != nil || u != "/even/4" {
+		t.Errorf("got %s %v | expected /even/4", u, err)
+	}
+	if _, err := r.URL("even", 5); err == nil {
+		t.Error("URL should reject a value that breaks the constraint")
+	}
+}
+
+func TestParamConstraintConflict(t *testing.T) {
+	// 约束不参与匹配，去掉约束后相同的路径模式冲突
+	r := NewRoute(nil)
+	r.GET("/u/{id:int}", func(c *HTTPContext) { c.String(http.StatusOK, c.Param("id")) })
+	defer func() {
+		if recover() == nil {
+			t.Error("got nil | expected /u/{name:alpha} to conflict with /u/{id:int}")
+		}
+	}()
+	r.GET("/u/{name:alpha}", func(c *HTTPContext) { c.String(http.StatusOK, c.Param("name")) })
+}
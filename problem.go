@@ -0,0 +1,190 @@
+package whttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"maps"
+	"net/http"
+	"slices"
+)
+
+// Problem RFC 9457 问题详情，可作为 error 传给 AbortWithError
+type Problem struct {
+	// 问题类型的 URI，缺省为 about:blank
+	Type string `json:"type,omitempty"`
+	// 问题类型的简短说明，缺省为状态码的说明
+	Title  string `json:"title,omitempty"`
+	Status int    `json:"status,omitempty"`
+	// 本次问题的说明
+	Detail string `json:"detail,omitempty"`
+	// 本次问题的 URI，缺省为请求路径
+	Instance string `json:"instance,omitempty"`
+	// 扩展成员，与标准成员平级输出
+	Extensions map[string]any `json:"-"`
+	// 原始错误
+	Err error `json:"-"`
+}
+
+// NewProblem 新建问题详情
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{Status: status, Title: http.StatusText(status), Detail: detail}
+}
+
+func (p *Problem) Error() string {
+	if len(p.Detail) > 0 {
+		return p.Detail
+	}
+	if p.Err != nil {
+		return p.Err.Error()
+	}
+	return p.Title
+}
+
+func (p *Problem) Unwrap() error {
+	return p.Err
+}
+
+// MarshalJSON 扩展成员排在标准成员之后，不覆盖标准成员
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	type problem Problem
+	b, err := json.Marshal((*problem)(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return b, err
+	}
+	b = b[:len(b)-1]
+	for _, k := range slices.Sorted(maps.Keys(p.Extensions)) {
+		switch k {
+		case "type", "title", "status", "detail", "instance":
+			continue
+		}
+		key, _ := json.Marshal(k)
+		value, err := json.Marshal(p.Extensions[k])
+		if err != nil {
+			return nil, err
+		}
+		if len(b) > 1 {
+			b = append(b, ',')
+		}
+		b = append(append(append(b, key...), ':'), value...)
+	}
+	return append(b, '}'), nil
+}
+
+// errorStatus 由错误推断状态码
+func errorStatus(err error) int {
+	var p *Problem
+	var maxErr *http.MaxBytesError
+	var bindErr *BindError
+	var validationErrs ValidationErrors
+	switch {
+	case errors.As(err, &p) && p.Status > 0:
+		return p.Status
+	case errors.As(err, &maxErr):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrUnsupportedMediaType):
+		return http.StatusUnsupportedMediaType
+	case errors.As(err, &bindErr), errors.As(err, &validationErrs):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// problemOf 将错误转为问题详情，5xx 错误不向客户端暴露原始信息
+func problemOf(c *HTTPContext, status int, err error) *Problem {
+	var p Problem
+	var src *Problem
+	if errors.As(err, &src) {
+		p = *src
+	} else {
+		p.Err = err
+		if status < http.StatusInternalServerError && err != nil {
+			p.Detail = err.Error()
+		}
+		var validationErrs ValidationErrors
+		if errors.As(err, &validationErrs) {
+			p.Extensions = map[string]any{"errors": validationErrs}
+		}
+	}
+	if p.Status == 0 {
+		p.Status = status
+	}
+	if len(p.Type) == 0 {
+		p.Type = "about:blank"
+	}
+	if len(p.Title) == 0 {
+		p.Title = http.StatusText(p.Status)
+	}
+	if len(p.Instance) == 0 && c.Request != nil {
+		p.Instance = c.Request.URL.Path
+	}
+	return &p
+}
+
+// DefaultErrorHandler 按 Accept 返回 application/problem+json、HTML 或纯文本
+func DefaultErrorHandler(c *HTTPContext, status int, err error) {
+	p := problemOf(c, status, err)
+	if p.Status >= http.StatusInternalServerError {
+		c.Error("errorHandler", "status", p.Status, "error", err, "url", c.Request.URL)
+	}
+	switch c.NegotiateFormat(MIMEApplicationProblemJSON, MIMEApplicationJSON, MIMETextHtml, MIMETextPlain) {
+	case MIMETextHtml:
+		title := html.EscapeString(fmt.Sprintf("%d %s", p.Status, p.Title))
+		c.Blob(p.Status, "text/html; charset=utf-8", fmt.Appendf(nil,
+			"<!DOCTYPE html>\n<html><head><title>%s</title></head><body><h1>%s</h1><p>%s</p></body></html>\n",
+			title, title, html.EscapeString(p.Detail)))
+	case MIMETextPlain:
+		msg := p.Detail
+		if len(msg) == 0 {
+			msg = p.Title
+		}
+		c.Blob(p.Status, MIMETextPlainUTF8, []byte(msg))
+	default:
+		data, err := json.Marshal(p)
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.Blob(p.Status, MIMEApplicationProblemJSON, data)
+	}
+}
+
+// AbortWithError 中止处理链，记录错误并交由 WRoute.ErrorHandler 返回，
+// 已写入响应时只记录错误
+func (c *HTTPContext) AbortWithError(status int, err error) {
+	c.alive()
+	c.Abort()
+	if err != nil {
+		c.errors = append(c.errors, err)
+	}
+	if c.Written() {
+		c.Warn("abortWithError: response already written", "error", err)
+		return
+	}
+	handler := DefaultErrorHandler
+	if c.route != nil && c.route.ErrorHandler != nil {
+		handler = c.route.ErrorHandler
+	}
+	handler(c, status, err)
+}
+
+// Errors 本次请求 AbortWithError 记录的错误
+func (c *HTTPContext) Errors() []error {
+	c.alive()
+	return c.errors
+}
+
+// WrapError 将返回错误的处理函数转为 func(*HTTPContext)，
+// 错误交由 AbortWithError，状态码由错误推断：*Problem 取其状态码，
+// 请求体过大 413，不支持的媒体类型 415，绑定与验证错误 400，其余 500
+func WrapError(fn func(*HTTPContext) error) func(*HTTPContext) {
+	if fn == nil {
+		panic("wrapError: handler cannot be nil")
+	}
+	return func(c *HTTPContext) {
+		if err := fn(c); err != nil {
+			c.AbortWithError(errorStatus(err), err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package whttp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProblemJSON(t *testing.T) {
+	p := &Problem{Type: "https://example.com/out-of-credit", Title: "You do not have enough credit.", Status: 403,
+		Detail: "Your current balance is 30, but that costs 50.", Instance: "/account/12345/msgs/abc",
+		Extensions: map[string]any{"balance": 30, "accounts": []string{"/account/12345"}, "status": 500}}
+	b, err := p.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"type":"https://example.com/out-of-credit","title":"You do not have enough credit.","status":403,"detail":"Your current balance is 30, but that costs 50.","instance":"/account/12345/msgs/abc","accounts":["/account/12345"],"balance":30}`
+	if string(b) != expected {
+		t.Errorf("got %s | expected %s", b, expected)
+	}
+}
+
+func TestErrorHandler(t *testing.T) {
+	type form struct {
+		Name string `json:"name" validate:"required"`
+	}
+	r := NewRoute(nil)
+	r.GET("/forbidden", func(c *HTTPContext) {
+		c.AbortWithError(http.StatusForbidden, errors.New("<b>denied</b>"))
+	})
+	r.GET("/internal", WrapError(func(c *HTTPContext) error {
+		return fmt.Errorf("db password leaked")
+	}))
+	r.GET("/problem", WrapError(func(c *HTTPContext) error {
+		return fmt.Errorf("wrap: %w", &Problem{Status: http.StatusConflict, Type: "urn:conflict", Detail: "version mismatch"})
+	}))
+	r.POST("/bind", WrapError(func(c *HTTPContext) error {
+		var f form
+		return c.BindJSON(&f)
+	}))
+	r.GET("/written", func(c *HTTPContext) {
+		c.String(http.StatusOK, "ok")
+		c.AbortWithError(http.StatusInternalServerError, errors.New("late"))
+		if len(c.Errors()) != 1 {
+			t.Errorf("got %d | expected 1 error", len(c.Errors()))
+		}
+	})
+	tests := []struct {
+		method, path, accept string
+		status               int
+		ctype, body          string
+	}{
+		{"GET", "/forbidden", "", 403, MIMEApplicationProblemJSON,
+			`{"type":"about:blank","title":"Forbidden","status":403,"detail":"\u003cb\u003edenied\u003c/b\u003e","instance":"/forbidden"}`},
+		{"GET", "/forbidden", "text/html,*/*;q=0.8", 403, "text/html; charset=utf-8", "<p>&lt;b&gt;denied&lt;/b&gt;</p>"},
+		{"GET", "/forbidden", "text/plain", 403, MIMETextPlainUTF8, "<b>denied</b>"},
+		{"GET", "/forbidden", "image/png", 403, MIMEApplicationProblemJSON, `"status":403`},
+		{"GET", "/internal", "", 500, MIMEApplicationProblemJSON,
+			`{"type":"about:blank","title":"Internal Server Error","status":500,"instance":"/internal"}`},
+		{"GET", "/problem", "", 409, MIMEApplicationProblemJSON,
+			`{"type":"urn:conflict","title":"Conflict","status":409,"detail":"version mismatch","instance":"/problem"}`},
+		{"POST", "/bind", "", 400, MIMEApplicationProblemJSON, `"errors":[{"field":"name","rule":"required"`},
+		{"GET", "/written", "", 200, "", "ok"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader("{}"))
+		if len(tt.accept) > 0 {
+			req.Header.Set("Accept", tt.accept)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != tt.status || rec.Header().Get("Content-Type") != tt.ctype || !strings.Contains(rec.Body.String(), tt.body) {
+			t.Errorf("%s %s: got %d %s %s | expected %d %s %s", tt.path, tt.accept, rec.Code, rec.Header().Get("Content-Type"),
+				rec.Body.String(), tt.status, tt.ctype, tt.body)
+		}
+	}
+	r.ErrorHandler = func(c *HTTPContext, status int, err error) {
+		c.String(status, "custom: "+err.Error())
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/forbidden", nil))
+	if rec.Body.String() != "custom: <b>denied</b>" {
+		t.Errorf("got %s | expected custom: <b>denied</b>", rec.Body.String())
+	}
+}
@@ -0,0 +1,189 @@
+package whttp
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+)
+
+// responseWriter 记录状态码、响应体字节数与响应头是否已写入，
+// 直接写入 c.Writer 的处理函数同样被记录；注册了 Transformer 时数据经变换管道写出。
+// Flush、Hijack、ReadFrom 转交底层，并可经 Unwrap 由 http.ResponseController 取得底层的 ResponseWriter
+type responseWriter struct {
+	http.ResponseWriter
+	c *HTTPContext
+	// 处理函数写入的状态码
+	status int
+	// 写入底层的字节数
+	size        int
+	wroteHeader bool
+	// 响应头已写入底层
+	committed    bool
+	transformers []Transformer
+	// 注册变换时处理函数在处理链中的位置
+	transformerIndex []int
+	// 生效的变换，靠近底层的在前
+	stages     []io.WriteCloser
+	stageIndex []int
+	// 管道入口，无变换时为 nil
+	top io.Writer
+}
+
+func (w *responseWriter) reset(rw http.ResponseWriter, c *HTTPContext) {
+	w.ResponseWriter = rw
+	w.c = c
+	w.status = 0
+	w.size = 0
+	w.wroteHeader = false
+	w.committed = false
+	clear(w.transformers)
+	w.transformers = w.transformers[:0]
+	w.transformerIndex = w.transformerIndex[:0]
+	clear(w.stages)
+	w.stages = w.stages[:0]
+	w.stageIndex = w.stageIndex[:0]
+	w.top = nil
+}
+
+// WriteHeader 只写入一次，1xx 信息响应（101 除外）不计入；存在变换时在此建立管道
+func (w *responseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	if status >= 100 && status < 200 && status != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.status = status
+	w.wroteHeader = true
+	var dst io.Writer = baseWriter{w}
+	h := w.ResponseWriter.Header()
+	for i, t := range w.transformers {
+		if s := t(h, status, dst); s != nil {
+			w.stages = append(w.stages, s)
+			w.stageIndex = append(w.stageIndex, w.transformerIndex[i])
+			dst = s
+		}
+	}
+	if w.c != nil && len(w.c.HookBeforWriteHeader) > 0 {
+		// 位于入口，最内层的 c.Next() 返回时关闭，之前追加的钩子均会执行
+		s := &hookStage{c: w.c, w: dst, buf: w.c.route.pool.AllocBuffer()}
+		w.stages = append(w.stages, s)
+		w.stageIndex = append(w.stageIndex, -1)
+		dst = s
+	}
+	if len(w.stages) == 0 {
+		w.commit()
+		return
+	}
+	w.top = dst
+}
+
+// commit 将响应头写入底层
+func (w *responseWriter) commit() {
+	if !w.committed {
+		w.committed = true
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.top != nil {
+		return w.top.Write(b)
+	}
+	return baseWriter{w}.Write(b)
+}
+
+// ReadFrom 无变换且底层实现 io.ReaderFrom 时使用其零拷贝路径，如 sendfile
+func (w *responseWriter) ReadFrom(r io.Reader) (n int64, err error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.top != nil {
+		return io.Copy(struct{ io.Writer }{w.top}, r)
+	}
+	w.commit()
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		n, err = io.Copy(struct{ io.Writer }{w.ResponseWriter}, r)
+	}
+	w.size += int(n)
+	return
+}
+
+// FlushError 未写入响应头时以 200 写入，依次刷新各变换后刷新底层
+func (w *responseWriter) FlushError() error {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	for i := len(w.stages) - 1; i >= 0; i-- {
+		if f, ok := w.stages[i].(interface{ Flush() error }); ok {
+			if err := f.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+	w.commit()
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *responseWriter) Flush() {
+	w.FlushError()
+}
+
+// Hijack 接管连接后视为已写入 101 响应头
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.status = http.StatusSwitchingProtocols
+		w.wroteHeader = true
+		w.committed = true
+	}
+	return conn, rw, err
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// closeAfter 由入口向底层依次关闭钩子及位置在 index 之后的处理函数注册的变换，写出其剩余数据；
+// 全部关闭后写出响应头
+func (w *responseWriter) closeAfter(index int) error {
+	var errs []error
+	for i := len(w.stages) - 1; i >= 0 && (w.stageIndex[i] > index || w.stageIndex[i] < 0); i-- {
+		if err := w.stages[i].Close(); err != nil {
+			errs = append(errs, err)
+		}
+		w.stages[i] = nil
+		w.stages = w.stages[:i]
+		w.stageIndex = w.stageIndex[:i]
+	}
+	if len(w.stages) > 0 {
+		w.top = w.stages[len(w.stages)-1]
+		return errors.Join(errs...)
+	}
+	w.top = nil
+	if w.wroteHeader && len(errs) == 0 {
+		w.commit()
+	}
+	return errors.Join(errs...)
+}
+
+// discard 不再关闭尚未关闭的变换，丢弃其中的数据
+func (w *responseWriter) discard() {
+	clear(w.stages)
+	w.stages = w.stages[:0]
+	w.stageIndex = w.stageIndex[:0]
+	w.top = nil
+}
+
+// close 处理链结束时关闭全部变换
+func (w *responseWriter) close() error {
+	return w.closeAfter(-2)
+}
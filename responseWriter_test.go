@@ -0,0 +1,107 @@
+package whttp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResponseWriter(t *testing.T) {
+	r := NewRoute(nil)
+	var got string
+	inspect := func(c *HTTPContext) {
+		c.Next()
+		got = fmt.Sprintf("%d %d %v", c.Status(), c.BytesWritten(), c.Written())
+	}
+	r.GET("/direct", inspect, func(c *HTTPContext) {
+		c.Writer.Write([]byte("hello"))
+		c.Writer.WriteHeader(http.StatusTeapot)
+	})
+	r.GET("/redirect", inspect, func(c *HTTPContext) {
+		http.Redirect(c.Writer, c.Request, "/direct", http.StatusFound)
+	})
+	r.GET("/early", inspect, func(c *HTTPContext) {
+		c.Writer.WriteHeader(http.StatusEarlyHints)
+		c.Writer.WriteHeader(http.StatusCreated)
+		io.Copy(c.Writer, strings.NewReader("abc"))
+	})
+	r.GET("/flush", inspect, func(c *HTTPContext) {
+		if err := http.NewResponseController(c.Writer).Flush(); err != nil {
+			t.Error(err)
+		}
+	})
+	r.GET("/none", inspect, func(c *HTTPContext) {})
+	tests := []struct {
+		path, expected string
+	}{
+		{"/direct", "200 5 true"},
+		{"/redirect", fmt.Sprintf("302 %d true", len(`<a href="/direct">Found</a>.`+"\n\n"))},
+		{"/early", "201 3 true"},
+		{"/flush", "200 0 true"},
+		{"/none", "0 0 false"},
+	}
+	// httptest.ResponseRecorder 将 1xx 视为最终状态码，使用真实的服务端
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	for _, tt := range tests {
+		resp, err := client.Get(ts.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if got != tt.expected {
+			t.Errorf("%s: got %s | expected %s", tt.path, got, tt.expected)
+		}
+	}
+}
+
+func TestResponseWriterHijack(t *testing.T) {
+	r := NewRoute(nil)
+	r.GET("/", func(c *HTTPContext) {
+		conn, rw, err := http.NewResponseController(c.Writer).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 6\r\nConnection: close\r\n\r\nraw ok")
+		rw.Flush()
+		if !c.Written() || c.Status() != http.StatusSwitchingProtocols {
+			t.Errorf("got %v %d | expected written 101 after hijack", c.Written(), c.Status())
+		}
+	})
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(bufio.NewReader(resp.Body))
+	if string(b) != "raw ok" {
+		t.Errorf("got %s | expected raw ok", b)
+	}
+}
+
+func TestResponseWriterPanicBuffered(t *testing.T) {
+	r := NewRoute(nil)
+	r.GET("/", CompressMiddleware(CompressConfig{}), func(c *HTTPContext) {
+		c.Writer.WriteHeader(http.StatusOK)
+		c.Writer.Write([]byte("partial"))
+		panic("handler failed")
+	})
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	// 数据仍缓存在变换中，尚未写出时返回 500
+	if rec.Code != http.StatusInternalServerError || len(rec.Header().Get("Content-Encoding")) > 0 || len(rec.Header().Get("Vary")) > 0 {
+		t.Errorf("got %d %v | expected 500 without Content-Encoding and Vary", rec.Code, rec.Header())
+	}
+}
@@ -6,12 +6,15 @@ import (
 	"io"
 	"io/fs"
 	"log/slog"
+	"maps"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
+	"sync"
 
 	"github.com/duomi520/utils"
 )
@@ -19,7 +22,8 @@ import (
 // DefaultMarshal 缺省JSON编码器
 var DefaultMarshal func(any) ([]byte, error) = json.Marshal
 
-// DefaultUnmarshal 缺省JSON解码器
+// DefaultUnmarshal 缺省JSON解码器，保持为 json.Unmarshal 时 BindJSON 流式解码，
+// 替换后读取完整的请求体（受请求体大小限制）再调用，严格模式始终使用 encoding/json
 var DefaultUnmarshal func([]byte, any) error = json.Unmarshal
 
 // Renderer 模板渲染接口
@@ -29,15 +33,40 @@ type Renderer interface {
 
 // WRoute 路由
 type WRoute struct {
+	// 根路由组，提供 GET、POST 等注册方法
+	RouteGroup
 	debugMode bool
-	Mux       *http.ServeMux
+	// 请求体最大字节数，0 不限制
+	maxBodySize int64
+	// BindJSON 拒绝未知字段及多余的数据
+	strictJSON bool
+	Mux        *http.ServeMux
 	//模版
 	renderer Renderer
 	//HTTPContext String、JSON、Render、File IO Write时错误的处理函数
 	HookIOWriteError func(*HTTPContext, int, error)
+	//未匹配到路由时的处理函数，经过全局中间件
+	NotFound func(*HTTPContext)
+	//路径已注册但方法未注册时的处理函数，经过全局中间件，Allow 头已设置
+	MethodNotAllowed func(*HTTPContext)
+	//AbortWithError 的处理函数，缺省为 DefaultErrorHandler
+	ErrorHandler func(*HTTPContext, int, error)
 	// 实例级中间件
 	middlewares []func(*HTTPContext)
 	pool        *utils.Pool
+	// 路由表，键为路径模式
+	mu     sync.RWMutex
+	routes map[string]*routeEntry
+	// 路由名称到路径模式
+	names map[string]string
+	// 虚拟主机与挂载的子路由
+	hosts  []virtualHost
+	mounts []mount
+	// 首个请求到达时合并全局中间件，此后不可再调用 Use
+	once                  sync.Once
+	serving               bool
+	notFoundChain         []func(*HTTPContext)
+	methodNotAllowedChain []func(*HTTPContext)
 	//logger
 	logger *slog.Logger
 }
@@ -45,13 +74,24 @@ type WRoute struct {
 // NewRoute 新建
 func NewRoute(l *slog.Logger) *WRoute {
 	r := WRoute{}
+	r.RouteGroup = RouteGroup{route: &r}
 	r.Mux = http.NewServeMux()
+	r.routes = make(map[string]*routeEntry)
+	r.names = make(map[string]string)
 	r.HookIOWriteError = func(c *HTTPContext, n int, err error) {
 		if err != nil {
 			pc, _, l, _ := runtime.Caller(2)
 			r.logger.Error("hookIOWriteError", "error", err, "funcForPC", runtime.FuncForPC(pc).Name(), "line", l, "written_bytes", n)
 		}
 	}
+	r.NotFound = func(c *HTTPContext) {
+		c.String(http.StatusNotFound, "404 page not found")
+	}
+	r.MethodNotAllowed = func(c *HTTPContext) {
+		c.Error(fmt.Sprintf("not a %s request", c.Request.Method), "url", c.Request.URL)
+		c.String(http.StatusMethodNotAllowed, "method not allowed")
+	}
+	r.ErrorHandler = DefaultErrorHandler
 	r.pool = &utils.Pool{}
 	if l == nil {
 		r.logger = slog.Default()
@@ -61,93 +101,254 @@ func NewRoute(l *slog.Logger) *WRoute {
 	return &r
 }
 
+// SetDebugMode 调试模式，开启时注册 DebugRoutesPattern 路由表页面
 func (r *WRoute) SetDebugMode(b bool) {
+	r.mu.Lock()
 	r.debugMode = b
+	_, ok := r.routes[DebugRoutesPattern]
+	r.mu.Unlock()
+	if b && !ok {
+		r.handle(http.MethodGet, DebugRoutesPattern, []func(*HTTPContext){r.routesHandler})
+	}
 }
 
 func (r *WRoute) SetRenderer(s Renderer) {
 	r.renderer = s
 }
 
-// Use 全局中间件 需放在最前面
+// SetMaxBodySize 全局请求体最大字节数，0 不限制，单个路由可用 BodyLimitMiddleware 覆盖。
+// 与 BodyLimitMiddleware 不同，Content-Length 超出时不直接返回 413，以便路由级设置放宽限制：
+// 读取超出的部分返回 *http.MaxBytesError，交给 WrapError 时返回 413
+func (r *WRoute) SetMaxBodySize(n int64) {
+	r.maxBodySize = n
+}
+
+// SetStrictJSON BindJSON 是否采用严格模式，拒绝未知字段及多余的数据
+func (r *WRoute) SetStrictJSON(b bool) {
+	r.strictJSON = b
+}
+
+// Use 全局中间件，对所有路由生效，与注册顺序无关，需在服务启动前调用
 func (r *WRoute) Use(g ...func(*HTTPContext)) {
 	for _, v := range g {
 		if v == nil {
 			panic("middleware cannot be nil")
 		}
 	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.serving {
+		panic("Use cannot be called after the route has started serving")
+	}
 	r.middlewares = append(r.middlewares, g...)
 }
 
-// GET 注册GET方法
-func (r *WRoute) GET(pattern string, fn ...func(*HTTPContext)) {
-	r.Mux.HandleFunc(pattern, r.wrap(fn, "GET"))
+// chainOf 全局中间件在前，返回新切片
+func (r *WRoute) chainOf(g []func(*HTTPContext)) []func(*HTTPContext) {
+	chain := make([]func(*HTTPContext), 0, len(r.middlewares)+len(g))
+	chain = append(chain, r.middlewares...)
+	return append(chain, g...)
+}
+
+// start 首个请求到达时预先合并各路由的完整处理链
+func (r *WRoute) start() {
+	r.once.Do(func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.serving = true
+		r.notFoundChain = r.chainOf([]func(*HTTPContext){r.NotFound})
+		r.methodNotAllowedChain = r.chainOf([]func(*HTTPContext){r.MethodNotAllowed})
+		for _, e := range r.routes {
+			for _, m := range e.methods {
+				m.chain = r.chainOf(m.handlers)
+			}
+		}
+	})
 }
 
-// POST 注册POST方法
-func (r *WRoute) POST(pattern string, fn ...func(*HTTPContext)) {
-	r.Mux.HandleFunc(pattern, r.wrap(fn, "POST"))
+// headResponseWriter 丢弃响应体，用于由 GET 自动应答 HEAD
+type headResponseWriter struct {
+	http.ResponseWriter
 }
 
-// PUT 注册PUT方法
-func (r *WRoute) PUT(pattern string, fn ...func(*HTTPContext)) {
-	r.Mux.HandleFunc(pattern, r.wrap(fn, "PUT"))
+func (w headResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
 }
 
-// DELETE 注册DELETE方法
-func (r *WRoute) DELETE(pattern string, fn ...func(*HTTPContext)) {
-	r.Mux.HandleFunc(pattern, r.wrap(fn, "DELETE"))
+func (w headResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
 }
 
-// HEAD 注册HEAD方法
-func (r *WRoute) HEAD(pattern string, fn ...func(*HTTPContext)) {
-	r.Mux.HandleFunc(pattern, r.wrap(fn, "HEAD"))
+// methodRoute 某一方法的路由
+type methodRoute struct {
+	// 注册时的路径模式，含参数约束
+	pattern string
+	// 路由自身的处理链，不含全局中间件
+	handlers []func(*HTTPContext)
+	// 合并全局中间件后的完整处理链
+	chain []func(*HTTPContext)
+	// 注册位置
+	source string
+	// 路径参数约束
+	rules []paramRule
 }
 
-// wrap 封装
-func (r *WRoute) wrap(g []func(*HTTPContext), method string) func(http.ResponseWriter, *http.Request) {
+// routeEntry 同一路径模式下按方法区分的路由
+type routeEntry struct {
+	pattern string
+	methods map[string]*methodRoute
+}
+
+// lookup 查找方法对应的路由，未注册 HEAD 时由 GET 应答
+func (e *routeEntry) lookup(method string) (m *methodRoute, head bool) {
+	m, ok := e.methods[method]
+	if !ok && method == http.MethodHead {
+		m = e.methods[http.MethodGet]
+		head = m != nil
+	}
+	return
+}
+
+// allow 已注册的方法，按字母排序
+func (e *routeEntry) allow() string {
+	methods := slices.Sorted(maps.Keys(e.methods))
+	if _, ok := e.methods[http.MethodGet]; ok {
+		if _, ok := e.methods[http.MethodHead]; !ok {
+			methods = append(methods, http.MethodHead)
+			slices.Sort(methods)
+		}
+	}
+	return strings.Join(methods, ", ")
+}
+
+// handle 按路径模式合并各方法的处理链，同一模式只向 Mux 注册一次
+func (r *WRoute) handle(method, pattern string, g []func(*HTTPContext)) {
+	if len(g) == 0 {
+		panic("handler cannot be empty")
+	}
 	for _, v := range g {
 		if v == nil {
 			panic("middleware cannot be nil")
 		}
 	}
+	method = strings.ToUpper(method)
+	muxPattern, rules, err := compilePattern(pattern)
+	if err != nil {
+		panic(err.Error())
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.routes[muxPattern]
+	if !ok {
+		e = &routeEntry{pattern: muxPattern, methods: make(map[string]*methodRoute)}
+		r.routes[muxPattern] = e
+		r.Mux.HandleFunc(muxPattern, r.wrap(e))
+	}
+	if _, ok := e.methods[method]; ok {
+		panic(fmt.Sprintf("%s %s already registered", method, pattern))
+	}
+	m := &methodRoute{pattern: pattern, handlers: g, source: callerSource(), rules: rules}
+	if r.serving {
+		m.chain = r.chainOf(g)
+	}
+	e.methods[method] = m
+}
+
+// wrap 封装
+func (r *WRoute) wrap(e *routeEntry) func(http.ResponseWriter, *http.Request) {
 	return func(rw http.ResponseWriter, req *http.Request) {
-		defer func() {
-			if v := recover(); v != nil {
-				const stackSize = 4096
-				buf := make([]byte, stackSize)
-				lenght := runtime.Stack(buf, false)
-				r.logger.Error("panic recovered", "error", v, "stack", string(buf[:lenght]))
-				if r.debugMode {
-					rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
-					rw.WriteHeader(http.StatusInternalServerError)
-					rw.Write(buf[:lenght])
-				} else {
-					http.Error(rw, "Internal Server Error", http.StatusInternalServerError)
-				}
-			}
-		}()
-		if !strings.EqualFold(method, req.Method) {
-			rw.Header().Set("Allow", method)
-			rw.WriteHeader(http.StatusMethodNotAllowed)
-			io.WriteString(rw, "method not allowed")
-			r.logger.Error(fmt.Sprintf("not a %s request", req.Method), "url", req.URL)
+		r.start()
+		r.mu.RLock()
+		m, head := e.lookup(strings.ToUpper(req.Method))
+		allow := ""
+		if m == nil {
+			allow = e.allow()
+		}
+		r.mu.RUnlock()
+		if m == nil {
+			rw.Header().Set("Allow", allow)
+			r.serve(rw, req, r.methodNotAllowedChain)
+			return
+		}
+		if !matchRules(req, m.rules) {
+			r.serve(rw, req, r.notFoundChain)
 			return
 		}
-		c := HTTPContextPool.Get().(*HTTPContext)
-		c.chain = append(c.chain, r.middlewares...)
-		c.chain = append(c.chain, g...)
-		c.Writer = rw
-		c.Request = req
-		c.route = r
-		c.chain[0](c)
-		c.reset()
-		HTTPContextPool.Put(c)
+		if head {
+			rw = headResponseWriter{rw}
+		}
+		r.serve(rw, req, m.chain)
+	}
+}
+
+// ServeHTTP 实现 http.Handler，未匹配的请求同样经过全局中间件
+func (r *WRoute) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if sub, params := r.matchHost(req); sub != nil {
+		for k, v := range params {
+			req.SetPathValue(k, v)
+		}
+		sub.ServeHTTP(rw, req)
+		return
+	}
+	r.start()
+	if _, pattern := r.Mux.Handler(req); len(pattern) == 0 {
+		r.serve(rw, req, r.notFoundChain)
+		return
+	}
+	r.Mux.ServeHTTP(rw, req)
+}
+
+// serve 从池中取出上下文执行预先合并的处理链
+func (r *WRoute) serve(rw http.ResponseWriter, req *http.Request, chain []func(*HTTPContext)) {
+	c := HTTPContextPool.Get().(*HTTPContext)
+	defer func() {
+		if v := recover(); v != nil {
+			const stackSize = 4096
+			buf := make([]byte, stackSize)
+			lenght := runtime.Stack(buf, false)
+			r.logger.Error("panic recovered", "error", v, "stack", string(buf[:lenght]))
+			// 响应头已写入底层时无法再返回 500
+			if c.rw.committed {
+				return
+			}
+			// 丢弃变换中尚未写出的数据及其设置的响应头
+			c.rw.discard()
+			for _, k := range []string{"Content-Encoding", "ETag", "Vary"} {
+				rw.Header().Del(k)
+			}
+			if r.debugMode {
+				rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+				rw.WriteHeader(http.StatusInternalServerError)
+				rw.Write(buf[:lenght])
+			} else {
+				http.Error(rw, "Internal Server Error", http.StatusInternalServerError)
+			}
+		}
+	}()
+	c.chain = chain
+	c.rw.reset(rw, c)
+	c.Writer = &c.rw
+	c.Request = req
+	c.route = r
+	c.body = req.Body
+	if r.maxBodySize > 0 && req.Body != nil && req.Body != http.NoBody {
+		req.Body = http.MaxBytesReader(rw, req.Body, r.maxBodySize)
+	}
+	c.chain[0](c)
+	if err := c.rw.close(); err != nil {
+		r.HookIOWriteError(c, c.rw.size, err)
+	}
+	if r.debugMode {
+		// 不放回池中，之后的使用由 alive 检测
+		c.released.Store(true)
+		return
 	}
+	c.reset()
+	HTTPContextPool.Put(c)
 }
 
 // Static 将指定目录下的静态文件映射到URL路径中,relativePath不支持中文
-func (r *WRoute) Static(relativePath, file string, group ...func(*HTTPContext)) {
+func (g *RouteGroup) Static(relativePath, file string, group ...func(*HTTPContext)) *Route {
 	if strings.Contains(relativePath, "..") || strings.Contains(file, "..") {
 		panic("path contains illegal characters '..'")
 	}
@@ -159,11 +360,11 @@ func (r *WRoute) Static(relativePath, file string, group ...func(*HTTPContext))
 	fn := func(c *HTTPContext) {
 		c.File(file)
 	}
-	r.GET(relativePath, append(group, fn)...)
+	return g.GET(relativePath, append(slices.Clip(group), fn)...)
 }
 
 // StaticFS 静态文件目录服务,目录名不支持中文
-func (r *WRoute) StaticFS(root string, group ...func(*HTTPContext)) {
+func (g *RouteGroup) StaticFS(root string, group ...func(*HTTPContext)) {
 	if strings.Contains(root, "..") {
 		panic("path contains illegal characters '..'")
 	}
@@ -195,7 +396,7 @@ func (r *WRoute) StaticFS(root string, group ...func(*HTTPContext)) {
 		fn := func(c *HTTPContext) {
 			c.File(path)
 		}
-		r.GET(escapeUrl, append(group, fn)...)
+		g.GET(escapeUrl, append(slices.Clip(group), fn)...)
 		return nil
 
 	}
@@ -0,0 +1,148 @@
+package whttp
+
+import (
+	"cmp"
+	"html/template"
+	"net/http"
+	"reflect"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DebugRoutesPattern 调试模式下路由表页面的路径
+var DebugRoutesPattern = "/debug/routes"
+
+// RouteInfo 已注册路由的描述
+type RouteInfo struct {
+	Method  string `json:"method"`
+	Pattern string `json:"pattern"`
+	// 路由名称
+	Name string `json:"name,omitempty"`
+	// 处理链最后一个函数
+	Handler string `json:"handler"`
+	// 全局中间件、组中间件及路由中间件，按执行顺序
+	Middlewares []string `json:"middlewares"`
+	// 注册位置 file:line
+	Source string `json:"source"`
+}
+
+// Routes 列出已注册的路由，含 StaticFS 遍历目录生成的路由
+func (r *WRoute) Routes() []RouteInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	global := make([]string, len(r.middlewares))
+	for i, fn := range r.middlewares {
+		global[i] = funcName(fn)
+	}
+	names := make(map[string]string, len(r.names))
+	for name, pattern := range r.names {
+		names[pattern] = name
+	}
+	var list []RouteInfo
+	for _, e := range r.routes {
+		for method, m := range e.methods {
+			g := m.handlers
+			info := RouteInfo{
+				Method:      method,
+				Pattern:     m.pattern,
+				Name:        names[m.pattern],
+				Handler:     funcName(g[len(g)-1]),
+				Middlewares: slices.Clone(global),
+				Source:      m.source,
+			}
+			for _, fn := range g[:len(g)-1] {
+				info.Middlewares = append(info.Middlewares, funcName(fn))
+			}
+			list = append(list, info)
+		}
+	}
+	for _, m := range r.mounts {
+		for _, info := range m.sub.Routes() {
+			info.Pattern = joinPath(m.prefix, info.Pattern)
+			list = append(list, info)
+		}
+	}
+	for _, v := range r.hosts {
+		for _, info := range v.sub.Routes() {
+			info.Pattern = v.pattern + info.Pattern
+			list = append(list, info)
+		}
+	}
+	slices.SortFunc(list, func(a, b RouteInfo) int {
+		if n := cmp.Compare(a.Pattern, b.Pattern); n != 0 {
+			return n
+		}
+		return cmp.Compare(a.Method, b.Method)
+	})
+	return list
+}
+
+// funcName 函数名，闭包形如 pkg.Func.func1
+func funcName(fn func(*HTTPContext)) string {
+	f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer())
+	if f == nil {
+		return "unknown"
+	}
+	return f.Name()
+}
+
+// routePkg 本包路径，用于跳过注册方法自身的调用帧
+var routePkg = reflect.TypeOf(WRoute{}).PkgPath()
+
+// callerSource 跳过 WRoute、RouteGroup 的注册方法与目录遍历，返回使用者的调用位置
+func callerSource() string {
+	pc := make([]uintptr, 32)
+	n := runtime.Callers(2, pc)
+	frames := runtime.CallersFrames(pc[:n])
+	for {
+		f, more := frames.Next()
+		switch {
+		case strings.HasPrefix(f.Function, routePkg+".(*WRoute)."),
+			strings.HasPrefix(f.Function, routePkg+".(*RouteGroup)."),
+			strings.HasPrefix(f.Function, "path/filepath."),
+			strings.HasPrefix(f.Function, "io/fs."):
+		default:
+			return f.File + ":" + strconv.Itoa(f.Line)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
+
+var routesTemplate = template.Must(template.New("routes").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>routes</title></head>
+<body>
+<table border="1" cellspacing="0" cellpadding="4">
+<tr><th>Method</th><th>Pattern</th><th>Handler</th><th>Middlewares</th><th>Source</th></tr>
+{{range .}}<tr><td>{{.Method}}</td><td>{{.Pattern}}</td><td>{{.Handler}}</td><td>{{range .Middlewares}}{{.}}<br>{{end}}</td><td>{{.Source}}</td></tr>
+{{end}}</table>
+</body>
+</html>`))
+
+// routesHandler 路由表页面，浏览器访问返回 HTML，否则返回 JSON
+func (r *WRoute) routesHandler(c *HTTPContext) {
+	r.mu.RLock()
+	debug := r.debugMode
+	r.mu.RUnlock()
+	if !debug {
+		r.NotFound(c)
+		return
+	}
+	list := r.Routes()
+	if strings.Contains(c.Request.Header.Get("Accept"), MIMETextHtml) {
+		buf := r.pool.AllocBuffer()
+		defer r.pool.FreeBuffer(buf)
+		if err := routesTemplate.Execute(buf, list); err != nil {
+			c.Error("routes", "error", err.Error())
+			c.String(http.StatusInternalServerError, "server error")
+			return
+		}
+		c.Blob(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
@@ -0,0 +1,88 @@
+package whttp
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRoutes(t *testing.T) {
+	r := NewRoute(nil)
+	r.Use(LoggerMiddleware())
+	api := r.Group("/api", HeaderMiddleware(map[string]string{"X-Api": "1"}))
+	api.GET("/users", func(c *HTTPContext) { c.String(http.StatusOK, "users") })
+	r.StaticFS("txt")
+	list := r.Routes()
+	var users *RouteInfo
+	files := 0
+	for i := range list {
+		if list[i].Pattern == "/api/users" {
+			users = &list[i]
+		}
+		if strings.HasPrefix(list[i].Pattern, "/txt/") {
+			files++
+		}
+	}
+	if users == nil {
+		t.Fatal("/api/users not listed")
+	}
+	if len(users.Middlewares) != 2 || !strings.Contains(users.Middlewares[0], "LoggerMiddleware") || !strings.Contains(users.Middlewares[1], "HeaderMiddleware") {
+		t.Errorf("unexpected middlewares %v", users.Middlewares)
+	}
+	if !strings.Contains(users.Source, "routeInfo_test.go") {
+		t.Errorf("got %s | expected routeInfo_test.go", users.Source)
+	}
+	if files == 0 {
+		t.Error("StaticFS routes not listed")
+	}
+}
+
+func TestDebugRoutes(t *testing.T) {
+	r := NewRoute(nil)
+	r.GET("/hi", func(c *HTTPContext) { c.String(http.StatusOK, "Hi") })
+	r.SetDebugMode(true)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	res, err := http.Get(ts.URL + DebugRoutesPattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list []RouteInfo
+	err = json.NewDecoder(res.Body).Decode(&list)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Errorf("got %d routes | expected 2", len(list))
+	}
+	req, err := http.NewRequest("GET", ts.URL+DebugRoutesPattern, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", "text/html")
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "<td>/hi</td>") {
+		t.Errorf("html table missing /hi: %s", string(data))
+	}
+	r.SetDebugMode(false)
+	res, err = http.Get(ts.URL + DebugRoutesPattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("got %d | expected 404", res.StatusCode)
+	}
+}
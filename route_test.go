@@ -2,6 +2,7 @@ package whttp
 
 import (
 	"bytes"
+	"fmt"
 	"html/template"
 	"io"
 	"log/slog"
@@ -117,6 +118,41 @@ func TestMiddleware(t *testing.T) {
 	}
 }
 
+func abortHandler(c *HTTPContext) {
+	c.String(http.StatusOK, "unreachable")
+}
+
+func TestAbort(t *testing.T) {
+	signature := ""
+	r := NewRoute(nil)
+	outer := func(c *HTTPContext) {
+		signature += "A1"
+		c.Next()
+		signature += fmt.Sprintf("A2 %v %d %d %v %s", c.IsAborted(), c.Status(), c.BytesWritten(), c.Written(), c.HandlerName())
+	}
+	guard := func(c *HTTPContext) {
+		signature += "B1"
+		c.Abort()
+		c.String(http.StatusTeapot, "teapot")
+		// 中止后调用 Next 不再执行后续函数
+		c.Next()
+		signature += "B2"
+	}
+	r.GET("/", outer, guard, abortHandler)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	expected := "A1B1B2A2 true 418 6 true github.com/duomi520/whttp.abortHandler"
+	if signature != expected {
+		t.Errorf("got %s | expected %s", signature, expected)
+	}
+	signature = ""
+	r.GET("/ok", outer, abortHandler)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/ok", nil))
+	expected = "A1A2 false 200 11 true github.com/duomi520/whttp.abortHandler"
+	if signature != expected {
+		t.Errorf("got %s | expected %s", signature, expected)
+	}
+}
+
 func TestMethod(t *testing.T) {
 	r := NewRoute(nil)
 	r.Mux = http.NewServeMux()
@@ -152,6 +188,178 @@ func TestMethod(t *testing.T) {
 time="2025-07-23 16:56:37" level=ERROR msg="not a POST request" url=/
 */
 
+func TestAnyMatch(t *testing.T) {
+	r := NewRoute(nil)
+	r.Mux = http.NewServeMux()
+	fn := func(c *HTTPContext) { c.String(http.StatusOK, c.Request.Method) }
+	r.Any("/any", fn)
+	r.Match([]string{"PATCH", "OPTIONS"}, "/match", fn)
+	tests := [][3]any{
+		{"TRACE", "/any", http.StatusOK},
+		{"PATCH", "/any", http.StatusOK},
+		{"PATCH", "/match", http.StatusOK},
+		{"OPTIONS", "/match", http.StatusOK},
+		{"GET", "/match", http.StatusMethodNotAllowed},
+	}
+	for i := range tests {
+		req := httptest.NewRequest(tests[i][0].(string), tests[i][1].(string), nil)
+		rec := httptest.NewRecorder()
+		r.Mux.ServeHTTP(rec, req)
+		if rec.Code != tests[i][2].(int) {
+			t.Errorf("%s %s expected %d got %d", tests[i][0], tests[i][1], tests[i][2], rec.Code)
+		}
+	}
+}
+
+func TestHeadFallback(t *testing.T) {
+	r := NewRoute(nil)
+	r.Mux = http.NewServeMux()
+	r.GET("/h", func(c *HTTPContext) {
+		c.Writer.Header().Set("X-Test", "head")
+		c.String(http.StatusOK, "body")
+	})
+	rec := httptest.NewRecorder()
+	r.Mux.ServeHTTP(rec, httptest.NewRequest("HEAD", "/h", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("got %d | expected 200", rec.Code)
+	}
+	if rec.Header().Get("X-Test") != "head" {
+		t.Errorf("got %s | expected head", rec.Header().Get("X-Test"))
+	}
+	if rec.Body.Len() > 0 {
+		t.Errorf("got %s | expected empty body", rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	r.Mux.ServeHTTP(rec, httptest.NewRequest("POST", "/h", nil))
+	if allow := rec.Header().Get("Allow"); allow != "GET, HEAD" {
+		t.Errorf("got %s | expected GET, HEAD", allow)
+	}
+}
+
+func TestMethodTable(t *testing.T) {
+	r := NewRoute(nil)
+	r.Mux = http.NewServeMux()
+	r.GET("/users", func(c *HTTPContext) { c.String(http.StatusOK, "GET") })
+	r.POST("/users", func(c *HTTPContext) { c.String(http.StatusOK, "POST") })
+	r.PUT("/users", func(c *HTTPContext) { c.String(http.StatusOK, "PUT") })
+	ts := httptest.NewServer(r.Mux)
+	defer ts.Close()
+	for _, method := range []string{"GET", "POST", "PUT"} {
+		req, err := http.NewRequest(method, ts.URL+"/users", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, []byte(method)) {
+			t.Errorf("got %s | expected %s", string(data), method)
+		}
+	}
+	req, err := http.NewRequest("DELETE", ts.URL+"/users", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("got %d | expected %d", res.StatusCode, http.StatusMethodNotAllowed)
+	}
+	if allow := res.Header.Get("Allow"); allow != "GET, HEAD, POST, PUT" {
+		t.Errorf("got %s | expected GET, HEAD, POST, PUT", allow)
+	}
+}
+
+func TestGroup(t *testing.T) {
+	signature := ""
+	mark := func(s string) func(*HTTPContext) {
+		return func(c *HTTPContext) {
+			signature += s
+			c.Next()
+		}
+	}
+	r := NewRoute(nil)
+	r.Mux = http.NewServeMux()
+	r.Use(mark("U"))
+	api := r.Group("/api/", mark("A"))
+	v1 := api.Group("/v1", mark("V"))
+	v1.GET("/users", func(c *HTTPContext) { c.String(http.StatusOK, c.Request.URL.Path) })
+	v1.GET("/", func(c *HTTPContext) { c.String(http.StatusOK, c.Request.URL.Path) })
+	api.POST("/ping", func(c *HTTPContext) { c.String(http.StatusOK, "pong") })
+	tests := [][3]string{
+		{"GET", "/api/v1/users", "UAV"},
+		{"GET", "/api/v1/x", "UAV"},
+		{"POST", "/api/ping", "UA"},
+	}
+	for i := range tests {
+		signature = ""
+		rec := httptest.NewRecorder()
+		r.Mux.ServeHTTP(rec, httptest.NewRequest(tests[i][0], tests[i][1], nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s expected 200 got %d", tests[i][1], rec.Code)
+		}
+		if signature != tests[i][2] {
+			t.Errorf("%s expected %s got %s", tests[i][1], tests[i][2], signature)
+		}
+	}
+	if v1.Prefix() != "/api/v1" {
+		t.Errorf("got %s | expected /api/v1", v1.Prefix())
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	var visited []string
+	r := NewRoute(nil)
+	r.Use(func(c *HTTPContext) {
+		visited = append(visited, c.Request.URL.Path)
+		c.Next()
+	})
+	r.NotFound = func(c *HTTPContext) {
+		c.JSON(http.StatusNotFound, H{"error": "not found"})
+	}
+	r.MethodNotAllowed = func(c *HTTPContext) {
+		c.JSON(http.StatusMethodNotAllowed, H{"allow": c.Writer.Header().Get("Allow")})
+	}
+	r.GET("/{$}", func(c *HTTPContext) { c.String(http.StatusOK, "Hi") })
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	tests := [][4]any{
+		{"GET", "/", http.StatusOK, "Hi"},
+		{"GET", "/none", http.StatusNotFound, `{"error":"not found"}`},
+		{"DELETE", "/", http.StatusMethodNotAllowed, `{"allow":"GET, HEAD"}`},
+	}
+	for i := range tests {
+		req, err := http.NewRequest(tests[i][0].(string), ts.URL+tests[i][1].(string), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != tests[i][2].(int) || string(data) != tests[i][3].(string) {
+			t.Errorf("%s %s expected %d %s got %d %s", tests[i][0], tests[i][1], tests[i][2], tests[i][3], res.StatusCode, string(data))
+		}
+	}
+	if len(visited) != len(tests) {
+		t.Errorf("got %v | expected every request to pass the global middleware", visited)
+	}
+}
+
 func TestFile(t *testing.T) {
 	r := NewRoute(nil)
 	r.Mux = http.NewServeMux()
@@ -236,6 +444,26 @@ func TestUse(t *testing.T) {
 	}
 }
 
+func TestUseAfterRoutes(t *testing.T) {
+	r := NewRoute(nil)
+	r.GET("/", func(c *HTTPContext) { c.String(http.StatusOK, "Hi") })
+	r.Use(func(c *HTTPContext) {
+		c.Writer.Header().Set("X-Use", "1")
+		c.Next()
+	})
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Header().Get("X-Use") != "1" {
+		t.Error("global middleware registered after the route was not applied")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Use after serving should panic")
+		}
+	}()
+	r.Use(func(c *HTTPContext) { c.Next() })
+}
+
 // 2025/07/23 09:53:26 DEBUG | 40.6µs        | 127.0.0.1:63189 | 200 | GET     | /                                        |       2 bytes
 type testUser struct {
 	Username string `json:"username"`
@@ -0,0 +1,87 @@
+package whttp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// Transformer 流式响应变换，在写入响应头前调用，可修改 h，返回包装下游 w 的写入端，返回 nil 时本次响应跳过该变换。
+// 响应头在首个字节到达底层时才写出，此前写入端仍可修改 h；处理链结束时调用 Close 写出剩余数据，
+// 写入端实现 Flush() error 时随 http.Flusher 一并刷新
+type Transformer func(h http.Header, status int, w io.Writer) io.WriteCloser
+
+// Transform 注册流式响应变换，需在写入响应头前调用，后注册的变换先处理处理函数写出的数据
+func (c *HTTPContext) Transform(t Transformer) {
+	c.alive()
+	if c.readonly {
+		panic(errReadonly)
+	}
+	if t == nil {
+		panic("transformer cannot be nil")
+	}
+	if c.rw.wroteHeader {
+		c.Warn("transform: response header already written")
+		return
+	}
+	c.rw.transformers = append(c.rw.transformers, t)
+	c.rw.transformerIndex = append(c.rw.transformerIndex, c.index)
+}
+
+// nopWriteCloser 无需收尾的写入端
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// NopCloser 包装无需收尾的 io.Writer，供 Transformer 返回
+func NopCloser(w io.Writer) io.WriteCloser {
+	return nopWriteCloser{w}
+}
+
+// bodyAllowed 该状态码的响应是否可以带有响应体
+func bodyAllowed(status int) bool {
+	return status >= http.StatusOK && status != http.StatusNoContent && status != http.StatusNotModified
+}
+
+// baseWriter 变换管道的末端，首个字节到达时写出响应头
+type baseWriter struct {
+	w *responseWriter
+}
+
+func (b baseWriter) Write(p []byte) (int, error) {
+	b.w.commit()
+	n, err := b.w.ResponseWriter.Write(p)
+	b.w.size += n
+	return n, err
+}
+
+// hookStage 兼容 HookBeforWriteHeader，缓存完整的响应体，结束时交给钩子处理
+type hookStage struct {
+	c   *HTTPContext
+	w   io.Writer
+	buf *bytes.Buffer
+}
+
+func (s *hookStage) Write(p []byte) (int, error) {
+	return s.buf.Write(p)
+}
+
+func (s *hookStage) Close() error {
+	pool := s.c.route.pool
+	defer pool.FreeBuffer(s.buf)
+	buf := s.buf
+	hooks := s.c.HookBeforWriteHeader
+	for i := len(hooks) - 1; i > -1; i-- {
+		buf = hooks[i](buf)
+		if buf == nil {
+			return errors.New("hookBeforWriteHeader return nil")
+		}
+	}
+	_, err := io.Copy(s.w, buf)
+	return err
+}
@@ -0,0 +1,179 @@
+package whttp
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// upperWriter 将字母转为大写，首次写入时设置响应头
+type upperWriter struct {
+	h       http.Header
+	w       io.Writer
+	started bool
+}
+
+func (u *upperWriter) Write(p []byte) (int, error) {
+	if !u.started {
+		u.started = true
+		u.h.Set("X-Upper", "1")
+	}
+	return u.w.Write(bytes.ToUpper(p))
+}
+
+func (u *upperWriter) Close() error {
+	return nil
+}
+
+func upperMiddleware(c *HTTPContext) {
+	c.Transform(func(h http.Header, status int, w io.Writer) io.WriteCloser {
+		h.Del("Content-Length")
+		return &upperWriter{h: h, w: w}
+	})
+	c.Next()
+}
+
+func TestTransform(t *testing.T) {
+	r := NewRoute(nil)
+	var n int
+	count := func(c *HTTPContext) {
+		c.Next()
+		n = c.BytesWritten()
+	}
+	hook := func(c *HTTPContext) {
+		c.HookBeforWriteHeader = append(c.HookBeforWriteHeader, func(b *bytes.Buffer) *bytes.Buffer {
+			b.WriteString("!")
+			return b
+		})
+		c.Next()
+	}
+	hello := func(c *HTTPContext) {
+		c.String(http.StatusOK, "hello")
+	}
+	r.GET("/", count, upperMiddleware, hello)
+	r.GET("/hook", count, upperMiddleware, hook, hello)
+	r.GET("/empty", count, upperMiddleware, func(c *HTTPContext) {
+		c.Writer.WriteHeader(http.StatusAccepted)
+	})
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Body.String() != "HELLO" || rec.Header().Get("X-Upper") != "1" || n != 5 {
+		t.Errorf("got %s %s %d | expected HELLO 1 5", rec.Body.String(), rec.Header().Get("X-Upper"), n)
+	}
+	// 兼容 HookBeforWriteHeader，钩子先于变换处理完整的响应体，外层中间件取得最终的字节数
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/hook", nil))
+	if rec.Body.String() != "HELLO!" || n != 6 {
+		t.Errorf("got %s %d | expected HELLO! 6", rec.Body.String(), n)
+	}
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/empty", nil))
+	if rec.Code != http.StatusAccepted || rec.Header().Get("X-Upper") != "" {
+		t.Errorf("got %d %s | expected 202 without X-Upper", rec.Code, rec.Header().Get("X-Upper"))
+	}
+}
+
+func TestTransformLargeFile(t *testing.T) {
+	dir := t.TempDir()
+	// 随机数据不可压缩，压缩后仍超出 ETag 与缓存的上限
+	large := make([]byte, ETagBufferSize+CacheMaxBodySize)
+	rng := rand.New(rand.NewPCG(1, 2))
+	for i := range large {
+		large[i] = byte(rng.Uint32())
+	}
+	if err := os.WriteFile(filepath.Join(dir, "large.txt"), large, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "small.txt"), []byte("small"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var etag sync.Map
+	var cache SyncMapCache
+	var n int
+	r := NewRoute(nil)
+	r.GET("/{name}", func(c *HTTPContext) {
+		c.Next()
+		n = c.BytesWritten()
+	}, ETagMiddleware(&etag), CacheMiddleware(&cache, nil), GZIPMiddleware(gzip.BestSpeed), func(c *HTTPContext) {
+		c.File(filepath.Join(dir, c.Request.PathValue("name")))
+	})
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	for _, tt := range []struct {
+		name   string
+		data   []byte
+		cached bool
+	}{{"large.txt", large, false}, {"small.txt", []byte("small"), true}} {
+		req, _ := http.NewRequest("GET", ts.URL+"/"+tt.name, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(zr)
+		if !bytes.Equal(data, tt.data) || n != len(body) {
+			t.Errorf("%s: got %d bytes, %d written | expected %d bytes, %d written", tt.name, len(data), n, len(tt.data), len(body))
+		}
+		_, stored := etag.Load("/" + tt.name)
+		if (len(resp.Header.Get("ETag")) > 0) != tt.cached || stored != tt.cached {
+			t.Errorf("%s: got ETag %q | expected %v", tt.name, resp.Header.Get("ETag"), tt.cached)
+		}
+		if _, ok := cacheLookup(&cache, "GET "+req.Host+"/"+tt.name, req); ok != tt.cached {
+			t.Errorf("%s: got cached %v | expected %v", tt.name, ok, tt.cached)
+		}
+	}
+}
+
+func TestTransformFlush(t *testing.T) {
+	next := make(chan struct{})
+	var etag sync.Map
+	r := NewRoute(nil)
+	r.GET("/", ETagMiddleware(&etag), GZIPMiddleware(gzip.DefaultCompression), func(c *HTTPContext) {
+		for i := range 3 {
+			c.Writer.Write([]byte("event " + strconv.Itoa(i) + "\n"))
+			c.Writer.(http.Flusher).Flush()
+			// 客户端收到本条后才继续，数据未被缓存
+			<-next
+		}
+	})
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	req, _ := http.NewRequest("GET", ts.URL, nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(zr)
+	for i := range 3 {
+		line, err := br.ReadString('\n')
+		if err != nil || line != "event "+strconv.Itoa(i)+"\n" {
+			t.Fatalf("got %q %v | expected event %d", line, err, i)
+		}
+		next <- struct{}{}
+	}
+	if len(resp.Header.Get("ETag")) > 0 || !strings.Contains(resp.Header.Get("Content-Encoding"), "gzip") {
+		t.Errorf("got %v | expected gzip without ETag", resp.Header)
+	}
+}
@@ -0,0 +1,92 @@
+package whttp
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Route 已注册的路由，可为其命名以便反向生成 URL
+type Route struct {
+	route   *WRoute
+	Pattern string
+}
+
+// Name 为路由命名，名称不可重复
+func (rt *Route) Name(name string) *Route {
+	if len(name) == 0 {
+		panic("route name cannot be empty")
+	}
+	r := rt.route
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p, ok := r.names[name]; ok && p != rt.Pattern {
+		panic(fmt.Sprintf("route name %s already used by %s", name, p))
+	}
+	r.names[name] = rt.Pattern
+	return rt
+}
+
+// URL 按路由名称生成路径，params 依次填充 {name} 与 {path...}，并做转义
+func (r *WRoute) URL(name string, params ...any) (string, error) {
+	r.mu.RLock()
+	pattern, ok := r.names[name]
+	r.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("route %s not found", name)
+	}
+	// 去掉主机部分
+	if i := strings.Index(pattern, "/"); i > 0 {
+		pattern = pattern[i:]
+	}
+	tokens, err := tokenize(pattern)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	n := 0
+	for _, tok := range tokens {
+		if len(tok.name) == 0 {
+			b.WriteString(tok.literal)
+			continue
+		}
+		if tok.name == "$" {
+			continue
+		}
+		if n >= len(params) {
+			return "", fmt.Errorf("route %s: missing value for {%s}", name, tok.name)
+		}
+		v := fmt.Sprint(params[n])
+		n++
+		if len(tok.rule) > 0 {
+			match, err := compileRule(tok.rule)
+			if err != nil {
+				return "", err
+			}
+			if !match(v) {
+				return "", fmt.Errorf("route %s: %q does not match {%s:%s}", name, v, tok.name, tok.rule)
+			}
+		}
+		if tok.multi {
+			segments := strings.Split(v, "/")
+			for k := range segments {
+				segments[k] = url.PathEscape(segments[k])
+			}
+			b.WriteString(strings.Join(segments, "/"))
+		} else {
+			b.WriteString(url.PathEscape(v))
+		}
+	}
+	if n != len(params) {
+		return "", errors.New("route " + name + ": too many params")
+	}
+	return b.String(), nil
+}
+
+// TemplateFuncs 模板函数，解析模板前通过 Funcs 注册，模板中 {{url "name" .ID}} 生成路径
+func (r *WRoute) TemplateFuncs() map[string]any {
+	return map[string]any{
+		"url": r.URL,
+	}
+}
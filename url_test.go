@@ -0,0 +1,54 @@
+package whttp
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestURL(t *testing.T) {
+	r := NewRoute(nil)
+	fn := func(c *HTTPContext) {}
+	r.GET("/users/{id}", fn).Name("user")
+	r.Group("/files").GET("/{path...}", fn).Name("file")
+	r.GET("/{$}", fn).Name("home")
+	tests := [][3]any{
+		{"user", []any{42}, "/users/42"},
+		{"user", []any{"a b/c"}, "/users/a%20b%2Fc"},
+		{"file", []any{"docs/我的 文件.txt"}, "/files/docs/%E6%88%91%E7%9A%84%20%E6%96%87%E4%BB%B6.txt"},
+		{"home", []any{}, "/"},
+	}
+	for i := range tests {
+		u, err := r.URL(tests[i][0].(string), tests[i][1].([]any)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u != tests[i][2].(string) {
+			t.Errorf("%s expected %s got %s", tests[i][0], tests[i][2], u)
+		}
+	}
+	if _, err := r.URL("user"); err == nil {
+		t.Error("missing param should fail")
+	}
+	if _, err := r.URL("user", 1, 2); err == nil {
+		t.Error("extra param should fail")
+	}
+	if _, err := r.URL("none"); err == nil {
+		t.Error("unknown name should fail")
+	}
+}
+
+func TestURLTemplate(t *testing.T) {
+	r := NewRoute(nil)
+	tl := template.Must(template.New("link").Funcs(r.TemplateFuncs()).Parse(`<a href="{{url "user" .}}">user</a>`))
+	r.SetRenderer(tl)
+	r.GET("/users/{id}", func(c *HTTPContext) {
+		c.Render(http.StatusOK, "link", c.Request.PathValue("id"))
+	}).Name("user")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/users/7", nil))
+	if rec.Body.String() != `<a href="/users/7">user</a>` {
+		t.Errorf("got %s | expected <a href=\"/users/7\">user</a>", rec.Body.String())
+	}
+}
@@ -0,0 +1,271 @@
+package whttp
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// DefaultValidate 缺省校验函数，Bind 系列方法绑定成功后自动调用，可替换为第三方校验器，为 nil 时不校验
+var DefaultValidate func(any) error = Validate
+
+// FieldError 字段校验失败
+type FieldError struct {
+	// 字段路径，优先使用 json 等标签中的名称，如 address.city、items[0].name
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors 全部校验失败的字段
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	var b strings.Builder
+	for i, v := range e {
+		if i > 0 {
+			b.WriteString("; ")
+		}
+		b.WriteString(v.Field + ": " + v.Message)
+	}
+	return b.String()
+}
+
+// ValidationRule 校验规则，param 为 = 之后的参数
+type ValidationRule func(v reflect.Value, param string) bool
+
+var validationRules = map[string]ValidationRule{
+	"required": func(v reflect.Value, _ string) bool { return !v.IsZero() },
+	"min": func(v reflect.Value, p string) bool {
+		return compareSize(v, p, func(a, b float64) bool { return a >= b })
+	},
+	"max": func(v reflect.Value, p string) bool {
+		return compareSize(v, p, func(a, b float64) bool { return a <= b })
+	},
+	"len": func(v reflect.Value, p string) bool {
+		return compareSize(v, p, func(a, b float64) bool { return a == b })
+	},
+	"eq": func(v reflect.Value, p string) bool {
+		return compareSize(v, p, func(a, b float64) bool { return a == b })
+	},
+	"ne": func(v reflect.Value, p string) bool {
+		return compareSize(v, p, func(a, b float64) bool { return a != b })
+	},
+	"gt": func(v reflect.Value, p string) bool {
+		return compareSize(v, p, func(a, b float64) bool { return a > b })
+	},
+	"gte": func(v reflect.Value, p string) bool {
+		return compareSize(v, p, func(a, b float64) bool { return a >= b })
+	},
+	"lt": func(v reflect.Value, p string) bool {
+		return compareSize(v, p, func(a, b float64) bool { return a < b })
+	},
+	"lte": func(v reflect.Value, p string) bool {
+		return compareSize(v, p, func(a, b float64) bool { return a <= b })
+	},
+	"oneof": func(v reflect.Value, p string) bool {
+		s := fmt.Sprint(v.Interface())
+		for _, o := range strings.Fields(p) {
+			if s == o {
+				return true
+			}
+		}
+		return false
+	},
+	"email": func(v reflect.Value, _ string) bool {
+		a, err := mail.ParseAddress(v.String())
+		return err == nil && a.Address == v.String()
+	},
+	"url": func(v reflect.Value, _ string) bool {
+		u, err := url.ParseRequestURI(v.String())
+		return err == nil && len(u.Scheme) > 0 && len(u.Host) > 0
+	},
+	"numeric": func(v reflect.Value, _ string) bool {
+		_, err := strconv.ParseFloat(v.String(), 64)
+		return err == nil
+	},
+	"alpha": func(v reflect.Value, _ string) bool { return isAlpha(v.String()) },
+	"alnum": func(v reflect.Value, _ string) bool { return isAlnum(v.String()) },
+	"uuid":  func(v reflect.Value, _ string) bool { return isUUID(v.String()) },
+	"ip":    func(v reflect.Value, _ string) bool { return net.ParseIP(v.String()) != nil },
+	"ipv4": func(v reflect.Value, _ string) bool {
+		ip := net.ParseIP(v.String())
+		return ip != nil && ip.To4() != nil
+	},
+	"ipv6": func(v reflect.Value, _ string) bool {
+		ip := net.ParseIP(v.String())
+		return ip != nil && ip.To4() == nil
+	},
+}
+
+// unknownRules 已记录日志的未注册规则
+var unknownRules sync.Map
+
+// RegisterValidation 注册自定义校验规则，需在处理请求前调用
+func RegisterValidation(name string, fn ValidationRule) {
+	if len(name) == 0 || fn == nil {
+		panic("validation name and function cannot be empty")
+	}
+	validationRules[name] = fn
+}
+
+// compareSize 字符串与容器比较长度，数值比较大小
+func compareSize(v reflect.Value, param string, cmp func(a, b float64) bool) bool {
+	p, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return false
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return cmp(float64(utf8.RuneCountInString(v.String())), p)
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return cmp(float64(v.Len()), p)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cmp(float64(v.Int()), p)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cmp(float64(v.Uint()), p)
+	case reflect.Float32, reflect.Float64:
+		return cmp(v.Float(), p)
+	}
+	return false
+}
+
+// Validate 按 `validate:"required,min=1,max=50,email,oneof=a b"` 标签校验结构体，
+// 嵌套结构体及其切片逐层校验，返回 ValidationErrors
+func Validate(v any) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	var errs ValidationErrors
+	validateStruct(rv, "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// fieldName 优先使用各绑定标签中的名称
+func fieldName(sf reflect.StructField) string {
+	for _, tag := range []string{"json", "xml", "form", "query", "path", "header"} {
+		name, _, _ := strings.Cut(sf.Tag.Get(tag), ",")
+		if len(name) > 0 && name != "-" {
+			return name
+		}
+	}
+	return sf.Name
+}
+
+func validateStruct(rv reflect.Value, prefix string, errs *ValidationErrors) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		fv := rv.Field(i)
+		tag := sf.Tag.Get("validate")
+		if tag == "-" {
+			continue
+		}
+		path := prefix
+		if !sf.Anonymous {
+			if len(path) > 0 {
+				path += "."
+			}
+			path += fieldName(sf)
+		}
+		if len(tag) > 0 {
+			if !validateField(fv, path, tag, errs) {
+				continue
+			}
+		}
+		validateNested(fv, path, errs)
+	}
+}
+
+// validateField 逐条执行规则，全部通过时返回 true
+func validateField(fv reflect.Value, path, tag string, errs *ValidationErrors) bool {
+	rules := strings.Split(tag, ",")
+	if slices.Contains(rules, "omitempty") && fv.IsZero() {
+		return true
+	}
+	v := fv
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	for _, r := range rules {
+		if r == "omitempty" || len(r) == 0 {
+			continue
+		}
+		name, param, _ := strings.Cut(r, "=")
+		fn, ok := validationRules[name]
+		if !ok {
+			// 未注册的规则属于编程错误，可能是第三方校验器的规则，不视为客户端的校验失败
+			if _, logged := unknownRules.LoadOrStore(name, struct{}{}); !logged {
+				slog.Warn("validate: unknown rule ignored, register it with RegisterValidation or replace DefaultValidate", "rule", name, "field", path)
+			}
+			continue
+		}
+		check := v
+		if name == "required" {
+			check = fv
+		}
+		if !fn(check, param) {
+			*errs = append(*errs, FieldError{Field: path, Rule: name, Param: param, Message: ruleMessage(name, param)})
+			return false
+		}
+	}
+	return true
+}
+
+// validateNested 校验嵌套的结构体、结构体切片
+func validateNested(fv reflect.Value, path string, errs *ValidationErrors) {
+	for fv.Kind() == reflect.Pointer {
+		if fv.IsNil() {
+			return
+		}
+		fv = fv.Elem()
+	}
+	switch fv.Kind() {
+	case reflect.Struct:
+		if fv.Type() == timeType {
+			return
+		}
+		validateStruct(fv, path, errs)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < fv.Len(); i++ {
+			validateNested(fv.Index(i), path+"["+strconv.Itoa(i)+"]", errs)
+		}
+	}
+}
+
+func ruleMessage(name, param string) string {
+	switch name {
+	case "required":
+		return "is required"
+	case "min", "max", "len", "eq", "ne", "gt", "gte", "lt", "lte":
+		return "must satisfy " + name + " " + param
+	case "oneof":
+		return "must be one of [" + param + "]"
+	}
+	if len(param) > 0 {
+		return "failed on " + name + "=" + param
+	}
+	return "must be a valid " + name
+}
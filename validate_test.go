@@ -0,0 +1,130 @@
+package whttp
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+type validateAddress struct {
+	City string `json:"city" validate:"required"`
+}
+
+type validateItem struct {
+	Name string `json:"name" validate:"required,max=5"`
+}
+
+type validateUser struct {
+	Name    string          `json:"name" validate:"required,min=1,max=50"`
+	Email   string          `json:"email" validate:"required,email"`
+	Role    string          `json:"role" validate:"oneof=admin user"`
+	Age     int             `json:"age" validate:"gte=0,lte=130"`
+	Website string          `json:"website" validate:"omitempty,url"`
+	Address validateAddress `json:"address"`
+	Items   []validateItem  `json:"items" validate:"min=1"`
+}
+
+func TestValidate(t *testing.T) {
+	ok := validateUser{Name: "linda", Email: "linda@example.com", Role: "admin", Age: 18,
+		Address: validateAddress{City: "fz"}, Items: []validateItem{{Name: "a"}}}
+	if err := Validate(&ok); err != nil {
+		t.Fatal(err)
+	}
+	bad := validateUser{Email: "linda", Role: "root", Age: 200, Website: "x",
+		Items: []validateItem{{Name: "a"}, {Name: "toolong"}}}
+	err := Validate(bad)
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("got %v | expected ValidationErrors", err)
+	}
+	got := make([]string, len(errs))
+	for i := range errs {
+		got[i] = errs[i].Field + ":" + errs[i].Rule
+	}
+	expected := []string{"name:required", "email:email", "role:oneof", "age:lte", "website:url", "address.city:required", "items[1].name:max"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v | expected %v", got, expected)
+	}
+}
+
+func TestRegisterValidation(t *testing.T) {
+	RegisterValidation("checkNameLen", func(v reflect.Value, _ string) bool {
+		return utf8.RuneCountInString(v.String()) < 5
+	})
+	type car struct {
+		Name string `json:"name" validate:"checkNameLen"`
+	}
+	if err := Validate(car{Name: "byd"}); err != nil {
+		t.Error(err)
+	}
+	if err := Validate(car{Name: "toyota"}); err == nil {
+		t.Error("expected checkNameLen to fail")
+	}
+}
+
+func TestValidateUnknownRule(t *testing.T) {
+	// 第三方校验器的规则只记录日志，不视为校验失败
+	type car struct {
+		Name  string `json:"name" validate:"required,excludesall=0x2C,max=5"`
+		Color string `json:"color" validate:"rgb|rgba"`
+	}
+	if err := Validate(car{Name: "byd"}); err != nil {
+		t.Error(err)
+	}
+	if err := Validate(car{Name: "toyota"}); err == nil {
+		t.Error("got nil | expected max to fail")
+	}
+}
+
+func TestValidateAlphaIndependent(t *testing.T) {
+	// 替换同名的路径参数约束不影响校验规则
+	old := paramConstraints["alpha"]
+	RegisterParamConstraint("alpha", func(string) bool { return true })
+	defer RegisterParamConstraint("alpha", old)
+	type code struct {
+		Name string `json:"name" validate:"alpha"`
+		ID   string `json:"id" validate:"alnum"`
+	}
+	if err := Validate(code{Name: "abc", ID: "a1"}); err != nil {
+		t.Error(err)
+	}
+	if err := Validate(code{Name: "a1", ID: "a-1"}); err == nil || len(err.(ValidationErrors)) != 2 {
+		t.Errorf("got %v | expected alpha and alnum errors", err)
+	}
+}
+
+func TestBindValidate(t *testing.T) {
+	r := NewRoute(nil)
+	r.POST("/users", func(c *HTTPContext) {
+		var u validateUser
+		if err := c.BindJSON(&u); err != nil {
+			var errs ValidationErrors
+			if errors.As(err, &errs) {
+				c.JSON(http.StatusBadRequest, errs)
+				return
+			}
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		c.String(http.StatusOK, u.Name)
+	})
+	req := httptest.NewRequest("POST", "/users", strings.NewReader(`{"name":"linda","email":"bad","role":"user","address":{"city":"fz"},"items":[{"name":"a"}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("got %d | expected 400", rec.Code)
+	}
+	var errs []FieldError
+	if err := json.Unmarshal(rec.Body.Bytes(), &errs); err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 1 || errs[0].Field != "email" || errs[0].Rule != "email" {
+		t.Errorf("unexpected %s", rec.Body.String())
+	}
+}